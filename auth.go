@@ -0,0 +1,505 @@
+package gooey
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Identity describes an authenticated user of a gooey Server.
+type Identity struct {
+	// The name of the user, such as a username or an email address.
+	Name string
+
+	// The roles granted to the user which are checked against the server's
+	// MessageRoles to authorize incoming messages.
+	Roles []string
+
+	// Any additional information an Authenticator wishes to pass on to the App,
+	// such as claims extracted from an SSO cookie.
+	Data interface{}
+}
+
+// HasRole reports whether the identity has been granted role.  A nil identity
+// has no roles.
+func (id *Identity) HasRole(role string) bool {
+	if id == nil {
+		return false
+	}
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates every HTTP request made to a gooey Server, which
+// includes requests for static files, the websocket upgrade, and new tab requests.
+type Authenticator interface {
+	// Authenticate returns the identity of the user making the request or an error
+	// if the request could not be authenticated.  The response writer is provided
+	// so an Authenticator may set cookies on a successful authentication but it
+	// must not write a response body.
+	Authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error)
+
+	// Challenge is called when Authenticate fails and must write a response to the
+	// client, such as a 401 status or a redirect to a login page.
+	Challenge(w http.ResponseWriter, r *http.Request)
+}
+
+// ErrUnauthenticated is returned by the built in Authenticators when a request
+// carries no credentials or the credentials are invalid.
+var ErrUnauthenticated = errors.New("gooey: request is not authenticated")
+
+// BasicAuth authenticates requests with HTTP basic authentication.
+type BasicAuth struct {
+	// The realm presented to the user by the browser's login prompt.  If this
+	// field is the empty string then "gooey" is used.
+	Realm string
+
+	// Validate is called with the credentials supplied by the browser and returns
+	// the identity of the user or an error if the credentials are invalid.
+	Validate func(user, password string) (*Identity, error)
+}
+
+func (a *BasicAuth) Authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok || a.Validate == nil {
+		return nil, ErrUnauthenticated
+	}
+	return a.Validate(user, password)
+}
+
+func (a *BasicAuth) Challenge(w http.ResponseWriter, r *http.Request) {
+	realm := a.Realm
+	if realm == "" {
+		realm = "gooey"
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+strings.Replace(realm, `"`, "", -1)+`"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// TokenAuth authenticates requests with a static secret token.  The token may
+// be given as a bearer token in the Authorization header, as the token query
+// parameter of the URL (e.g. http://127.0.0.1:8080/?token=secret), or through
+// a cookie that TokenAuth sets once the token has been presented.  The cookie
+// allows a browser opened with the token in its URL to continue to request files
+// and open the websocket without the token in every URL.
+type TokenAuth struct {
+	// The secret token that requests must present.  An empty Token rejects
+	// all requests.
+	Token string
+
+	// The identity given to authenticated requests.  If nil then the identity
+	// will have the name "token".
+	Identity *Identity
+}
+
+const tokenCookie = "gooey-token"
+
+func (a *TokenAuth) Authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	if a.Token == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	var (
+		given   string
+		fromURL bool
+	)
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		given = strings.TrimPrefix(h, "Bearer ")
+	} else if q := r.URL.Query().Get("token"); q != "" {
+		given, fromURL = q, true
+	} else if c, err := r.Cookie(tokenCookie); err == nil {
+		given = c.Value
+	}
+
+	if subtle.ConstantTimeCompare([]byte(given), []byte(a.Token)) != 1 {
+		return nil, ErrUnauthenticated
+	}
+	if fromURL {
+		http.SetCookie(w, &http.Cookie{
+			Name:     tokenCookie,
+			Value:    a.Token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+
+	if a.Identity != nil {
+		return a.Identity, nil
+	}
+	return &Identity{Name: "token"}, nil
+}
+
+func (a *TokenAuth) Challenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// CookieAuth authenticates requests with a cookie issued by some other service,
+// such as a single sign-on provider.
+type CookieAuth struct {
+	// The name of the cookie to validate.
+	Cookie string
+
+	// Validate is called with the value of the cookie and returns the identity of
+	// the user or an error if the cookie is invalid.
+	Validate func(value string) (*Identity, error)
+
+	// If LoginURL is not the empty string then unauthenticated requests are
+	// redirected to it with the originally requested URL in the next query
+	// parameter.  Otherwise, a 401 status is returned.
+	LoginURL string
+}
+
+func (a *CookieAuth) Authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	c, err := r.Cookie(a.Cookie)
+	if err != nil || a.Validate == nil {
+		return nil, ErrUnauthenticated
+	}
+	return a.Validate(c.Value)
+}
+
+func (a *CookieAuth) Challenge(w http.ResponseWriter, r *http.Request) {
+	if a.LoginURL == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	login, err := url.Parse(a.LoginURL)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	q := login.Query()
+	q.Set("next", requestURL(r))
+	login.RawQuery = q.Encode()
+	http.Redirect(w, r, login.String(), http.StatusFound)
+}
+
+// FormAuth authenticates requests with a username and password entered into a
+// minimal login page that is served by gooey at /gooeylogin.  A successful login
+// creates a session for the user that is kept in memory for the lifetime of the
+// server and is tracked with a cookie.  Visiting /gooeylogout ends the session.
+type FormAuth struct {
+	// The title shown on the login page.  If this field is the empty string then
+	// "Gooey Login" is used.
+	Title string
+
+	// Validate is called with the credentials entered into the login page and
+	// returns the identity of the user or an error if the credentials are invalid.
+	Validate func(user, password string) (*Identity, error)
+
+	mu       sync.Mutex
+	sessions map[string]*Identity
+}
+
+const (
+	sessionCookie = "gooey-session"
+	loginPath     = "/gooeylogin"
+	logoutPath    = "/gooeylogout"
+)
+
+func (a *FormAuth) Authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id, ok := a.sessions[c.Value]; ok {
+		return id, nil
+	}
+	return nil, ErrUnauthenticated
+}
+
+func (a *FormAuth) Challenge(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(w, r, loginPath+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+}
+
+// ServeHTTP serves the login page and handles the submission of its form along
+// with logging out of a session.  The gooey Server routes requests for
+// /gooeylogin and /gooeylogout to this method without authenticating them.
+func (a *FormAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == logoutPath {
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			a.mu.Lock()
+			delete(a.sessions, c.Value)
+			a.mu.Unlock()
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+		http.Redirect(w, r, loginPath, http.StatusFound)
+		return
+	}
+
+	next := r.FormValue("next")
+	if !localRedirect(next) {
+		next = "/"
+	}
+
+	page := struct {
+		Title, Next, Failed string
+	}{
+		Title: a.Title,
+		Next:  next,
+	}
+	if page.Title == "" {
+		page.Title = "Gooey Login"
+	}
+
+	if r.Method == http.MethodPost && a.Validate != nil {
+		id, err := a.Validate(r.PostFormValue("user"), r.PostFormValue("password"))
+		if err == nil && id != nil {
+			token, err := randomToken()
+			if err != nil {
+				http.Error(w, "Failed to create session", http.StatusInternalServerError)
+				return
+			}
+
+			a.mu.Lock()
+			if a.sessions == nil {
+				a.sessions = make(map[string]*Identity)
+			}
+			a.sessions[token] = id
+			a.mu.Unlock()
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     sessionCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+			http.Redirect(w, r, next, http.StatusFound)
+			return
+		}
+		page.Failed = "Invalid username or password."
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	loginTemplate.Execute(w, page)
+}
+
+// Reports whether next is a path of this server that is safe to redirect to after
+// a login.  Browsers treat a backslash as a slash, and drop tabs and newlines, so
+// "/\evil.com" is as much a redirect to another host as "//evil.com".
+func localRedirect(next string) bool {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsAny(next, "\\\t\r\n") {
+		return false
+	}
+	u, err := url.Parse(next)
+	return err == nil && u.Scheme == "" && u.Host == "" && u.User == nil
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(LOGIN))
+
+// Returns a hex encoded random string suitable for session identifiers.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Returns the absolute URL of the request.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+type identityKey struct{}
+
+// Wraps the handler so that every request is authenticated with the server's
+// Authenticator before it is handled.  The identity of an authenticated request
+// is stored in the request's context.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if login, ok := s.Authenticator.(http.Handler); ok {
+			if r.URL.Path == loginPath || r.URL.Path == logoutPath {
+				login.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		id, err := s.Authenticator.Authenticate(w, r)
+		if err != nil || id == nil {
+			s.infoln("Rejected unauthenticated request for", r.URL.Path, "from", r.RemoteAddr)
+			s.Authenticator.Challenge(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// Reports whether the websocket upgrade request may be accepted from its origin.  A
+// server without an Authenticator accepts every origin, while one with an
+// Authenticator only accepts its own origin and the AllowedOrigins, since the
+// browser sends the user's credentials along with the upgrade request of any page.
+// Requests without an Origin header are not made by a browser's page.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	if s.Authenticator == nil {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range s.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the identity stored in the request's context by the server's
+// Authenticator, or nil if there is none.
+func requestIdentity(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey{}).(*Identity)
+	return id
+}
+
+// The content of the error message sent to the client when an incoming message
+// is rejected by the server.
+type messageError struct {
+	Code, Message, Type string
+}
+
+// Returns the type of an incoming message and whether the type is unambiguous.  By
+// default the type is the Type field of a JSON object and if that is missing then
+// the Method field, which allows for RPC style messages.  Since an App may decode
+// the message with a case insensitive decoder, as encoding/json is, or dispatch on
+// either field, a message is ambiguous if it has more than one key matching type
+// or method regardless of case, if either is not a string, or if Type and Method
+// are both set and differ.
+func (s *Server) messageType(msg []byte) (string, bool) {
+	if s.MessageType != nil {
+		return s.MessageType(msg), true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", true
+	}
+
+	var (
+		typ, method    string
+		types, methods int
+	)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", false
+		}
+		key, _ := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return "", false
+		}
+
+		var field *string
+		if strings.EqualFold(key, "type") {
+			field = &typ
+			types++
+		} else if strings.EqualFold(key, "method") {
+			field = &method
+			methods++
+		} else {
+			continue
+		}
+		if string(value) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(value, field); err != nil {
+			return "", false
+		}
+	}
+
+	if types > 1 || methods > 1 {
+		return "", false
+	}
+	if typ != "" && method != "" && typ != method {
+		return "", false
+	}
+	if typ != "" {
+		return typ, true
+	}
+	return method, true
+}
+
+// Checks that the session is allowed to send msg to the App according to the
+// server's MessageRoles.  If the message is unauthorized then the returned error
+// describes the rejection to be sent back to the client.
+func (s *Server) authorize(session *Session, msg []byte) *messageError {
+	if len(s.MessageRoles) == 0 {
+		return nil
+	}
+
+	typ, ok := s.messageType(msg)
+	if !ok {
+		s.errorln("Rejected message with an ambiguous type from", session.RemoteAddr)
+		return &messageError{
+			Code:    "ambiguous",
+			Message: "Message has more than one type or method",
+		}
+	}
+
+	roles, ok := s.MessageRoles[typ]
+	if !ok {
+		if roles, ok = s.MessageRoles["*"]; !ok {
+			return nil
+		}
+	}
+	// A type listed with no roles is open to everyone, which exempts it from "*".
+	if len(roles) == 0 {
+		return nil
+	}
+
+	for _, role := range roles {
+		if session.Identity.HasRole(role) {
+			return nil
+		}
+	}
+
+	name := "anonymous"
+	if session.Identity != nil {
+		name = session.Identity.Name
+	}
+	s.errorln("Rejected unauthorized message of type", strconv.Quote(typ), "from", name, "at", session.RemoteAddr)
+
+	return &messageError{
+		Code:    "unauthorized",
+		Message: "Not authorized to send messages of type " + strconv.Quote(typ),
+		Type:    typ,
+	}
+}
@@ -0,0 +1,137 @@
+package gooey
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizeMessageTypes(t *testing.T) {
+	var (
+		deleteRoles = map[string][]string{"delete": {"admin"}}
+		exemptRoles = map[string][]string{"*": {"admin"}, "view": {}, "list": nil}
+	)
+	anonymous := &Session{RemoteAddr: "test"}
+	admin := &Session{Identity: &Identity{Name: "admin", Roles: []string{"admin"}}, RemoteAddr: "test"}
+
+	tests := []struct {
+		roles     map[string][]string // The server's MessageRoles, deleteRoles if nil.
+		msg       string
+		anonymous bool // Whether the message is passed on for an anonymous session.
+		admin     bool // Whether the message is passed on for an admin session.
+	}{
+		{nil, `{"Type":"view"}`, true, true},
+		{nil, `{"Method":"view"}`, true, true},
+		{nil, `{"Type":"delete"}`, false, true},
+		{nil, `{"Method":"delete"}`, false, true},
+		{nil, `{"type":"delete"}`, false, true},
+		{nil, `{"METHOD":"delete"}`, false, true},
+		{nil, `{"Type":"delete","Method":"delete"}`, false, true},
+		{nil, `{"Type":"view","Method":"view"}`, true, true},
+		{nil, `{"Type":"view","Method":"delete"}`, false, false},
+		{nil, `{"Type":"delete","Method":"view"}`, false, false},
+		{nil, `{"type":"delete","Type":"view"}`, false, false},
+		{nil, `{"Type":"view","type":"view"}`, false, false},
+		{nil, `{"Type":"view","Type":"delete"}`, false, false},
+		{nil, `{"method":"delete","Method":"view"}`, false, false},
+		{nil, `{"Type":1}`, false, false},
+		{nil, `{"Type":"view","Method":["delete"]}`, false, false},
+		{nil, `{"Type":null,"Method":"delete"}`, false, true},
+		{nil, `{"Params":{"Type":"delete"}}`, true, true},
+		{nil, `["delete"]`, true, true},
+		{nil, `"delete"`, true, true},
+		{nil, `not json`, true, true},
+
+		// A type listed with no roles is exempt from the "*" entry.
+		{exemptRoles, `{"Type":"view"}`, true, true},
+		{exemptRoles, `{"Type":"list"}`, true, true},
+		{exemptRoles, `{"Type":"delete"}`, false, true},
+		{exemptRoles, `{"Type":"view","Method":"delete"}`, false, false},
+		{exemptRoles, `not json`, false, true},
+	}
+
+	for _, test := range tests {
+		server := &Server{MessageRoles: test.roles}
+		if server.MessageRoles == nil {
+			server.MessageRoles = deleteRoles
+		}
+		if got := server.authorize(anonymous, []byte(test.msg)) == nil; got != test.anonymous {
+			t.Errorf("%v authorize(anonymous, %s) passed = %v, want %v", server.MessageRoles, test.msg, got, test.anonymous)
+		}
+		if got := server.authorize(admin, []byte(test.msg)) == nil; got != test.admin {
+			t.Errorf("%v authorize(admin, %s) passed = %v, want %v", server.MessageRoles, test.msg, got, test.admin)
+		}
+	}
+}
+
+func TestAuthorizeCustomMessageType(t *testing.T) {
+	server := &Server{
+		MessageRoles: map[string][]string{"delete": {"admin"}},
+		MessageType:  func(msg []byte) string { return string(msg) },
+	}
+	session := &Session{RemoteAddr: "test"}
+
+	if e := server.authorize(session, []byte("delete")); e == nil {
+		t.Errorf("authorize passed a message whose custom type requires a role")
+	}
+	if e := server.authorize(session, []byte(`{"Type":"view","Method":"delete"}`)); e != nil {
+		t.Errorf("authorize rejected a message by its fields in place of its custom type -- %s", e.Message)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		auth    bool
+		origin  string
+		allowed bool
+	}{
+		{false, "https://evil.com", true},
+		{true, "", true},
+		{true, "http://127.0.0.1:8080", true},
+		{true, "http://LOCALHOST:8080", false},
+		{true, "http://127.0.0.1:9090", false},
+		{true, "https://evil.com", false},
+		{true, "https://app.example.com", true},
+		{true, "http://app.example.com", false},
+		{true, "null", false},
+	}
+
+	for _, test := range tests {
+		server := &Server{AllowedOrigins: []string{"https://app.example.com/"}}
+		if test.auth {
+			server.Authenticator = &TokenAuth{Token: "secret"}
+		}
+		r := httptest.NewRequest("GET", "http://127.0.0.1:8080/gooeywebsocket", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if got := server.checkOrigin(r); got != test.allowed {
+			t.Errorf("checkOrigin(%q) with authenticator %v = %v, want %v", test.origin, test.auth, got, test.allowed)
+		}
+	}
+}
+
+func TestLocalRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		ok   bool
+	}{
+		{"/", true},
+		{"/logs?filter=a%2Fb", true},
+		{"/a/b#top", true},
+		{"", false},
+		{"logs", false},
+		{"//evil.com", false},
+		{"/\\evil.com", false},
+		{"/\\/evil.com", false},
+		{"/\t/evil.com", false},
+		{"/\n/evil.com", false},
+		{"https://evil.com", false},
+		{"http:/evil.com", false},
+	}
+
+	for _, test := range tests {
+		if got := localRedirect(test.next); got != test.ok {
+			t.Errorf("localRedirect(%q) = %v, want %v", test.next, got, test.ok)
+		}
+	}
+}
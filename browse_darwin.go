@@ -1,3 +1,11 @@
 package gooey
 
 const BROWSE = "open"
+
+// The Chromium based browsers searched for by AppWindow.
+var appBrowsers = []string{
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+	"/Applications/Brave Browser.app/Contents/MacOS/Brave Browser",
+}
@@ -1,3 +1,13 @@
 package gooey
 
 const BROWSE = "xdg-open"
+
+// The Chromium based browsers searched for by AppWindow.
+var appBrowsers = []string{
+	"google-chrome",
+	"google-chrome-stable",
+	"chromium",
+	"chromium-browser",
+	"microsoft-edge",
+	"brave-browser",
+}
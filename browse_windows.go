@@ -1,3 +1,13 @@
 package gooey
 
 const BROWSE = "explorer.exe"
+
+// The Chromium based browsers searched for by AppWindow.
+var appBrowsers = []string{
+	`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+	`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+	`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+	`C:\Program Files\Microsoft\Edge\Application\msedge.exe`,
+	"chrome.exe",
+	"msedge.exe",
+}
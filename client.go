@@ -0,0 +1,923 @@
+package gooey
+
+// This file is generated by "go run setup.go client" from gooey.js and gooey.d.ts.
+// Do not modify.
+
+// The version of the gooey client, which is a hash of gooey.js and gooey.d.ts.
+const CLIENT_VERSION = "1ed650e309ae"
+
+// The gooey client, gooey.js, as a classic script.
+const CLIENT_JS = `(function () {
+    const CONNECTING = 0;
+    const OPEN       = 1;
+    const CLOSING    = 2;
+    const CLOSED     = 3;
+
+    // A page opened in a named window carries the window's name in its URL, which
+    // is kept as the name of the browser window so it outlives the page.  The
+    // server is told the window's name along with the page's URL.
+    let params = new URLSearchParams(window.location.search);
+    if (params.has('gooeywindow')) {
+        window.name = params.get('gooeywindow');
+    }
+
+    let scheme   = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+    let socket   = new WebSocket(scheme + window.location.host + '/gooeywebsocket' +
+                                 '?window=' + encodeURIComponent(window.name) +
+                                 '&page=' + encodeURIComponent(window.location.pathname + window.location.search));
+    let gooey    = undefined;
+
+    // Dynamically created <script> and <style> elements must carry the nonce
+    // of the page to be allowed under a Content-Security-Policy.  There is no
+    // current script when run as a module so the nonce is taken from any script
+    // of the page instead.
+    let nonceScript = document.currentScript || document.querySelector('script[nonce]');
+    let nonce       = nonceScript ? nonceScript.nonce : '';
+
+    // Refer to gooey instead of window.gooey for better minification.
+    if (window.hasOwnProperty("gooey")) {
+        gooey = window.gooey;
+    } else {
+        gooey = {};
+        window.gooey = gooey;
+
+        gooey.OnMessage = function(msg) { console.log(msg); };
+        gooey.OnReload = function(evt) {
+            console.log('[GOOEY] Reload event ' + evt.Event + ' for ' + evt.Path);
+        };
+        gooey.OnNavigate = function(page) {
+            console.log('[GOOEY] Navigated to ' + page);
+        };
+        gooey.OnError = function(err) {
+            console.error('[GOOEY] ' + err.Code + ': ' + err.Message);
+        };
+        gooey.Send = function(payload) {
+            if (socket.readyState === OPEN) {
+                socket.send(JSON.stringify(payload));
+            } else {
+                console.error('[GOOEY] Websocket connection is not open.');
+            }
+        };
+        gooey.IsDisconnected = false;
+        gooey.OnOpen = function() {
+            console.log('[GOOEY] Websocket connection is open.');
+        };
+        gooey.OnDisconnect = function() {
+            console.error('[GOOEY] Disconnected from server.');
+        };
+        gooey.OpenNewTab = function() {
+            let req = new XMLHttpRequest();
+            req.open('GET', window.location.origin + '/gooeynewtab', true);
+            req.send();
+        };
+        // Changes the page's URL to path, which may include a query, without
+        // loading a new page or closing the connection to the server.  The
+        // server starts the App of the path's route, replacing the body of the
+        // page if the route's page differs, and then gooey.OnNavigate is called.
+        gooey.Navigate = function(path) {
+            window.history.pushState(null, '', path);
+            navigate();
+        };
+        // Opens the page at path, with the query parameters in the params
+        // object, in the browser window called name.  If a window of that name
+        // is open then the page replaces its current one.  If the browser blocks
+        // the window from opening then the server opens it instead.
+        gooey.OpenWindow = function(name, path, params) {
+            let query = new URLSearchParams(params || {});
+            let url   = new URL(path, window.location.origin);
+            query.forEach(function(value, key) {
+                url.searchParams.append(key, value);
+            });
+            url.searchParams.set('gooeywindow', name);
+
+            if (window.open(url.toString(), name) === null) {
+                let req = new XMLHttpRequest();
+                req.open('GET', window.location.origin + '/gooeynewtab' +
+                         '?window=' + encodeURIComponent(name) +
+                         '&path=' + encodeURIComponent(path) +
+                         '&query=' + encodeURIComponent(query.toString()), true);
+                req.send();
+            }
+        };
+
+        // Hot module replacement for the Javascript files hot reloaded from the
+        // server's ReloadWatchDir.  Each file is a module and when a module
+        // changes the page is reloaded unless the module has called accept,
+        // in which case the module's dispose handlers are called and then the
+        // new version of the module is run in place of the old one.  Both
+        // functions must be called while the module's top level code runs.
+        gooey.hot = {
+            // Declares that the running module can be replaced.  When the
+            // module is run as the replacement of an earlier version then fn
+            // is called with the data object filled by the dispose handlers.
+            accept: function(fn) {
+                let mod = currentModule('accept');
+                if (mod) {
+                    mod.accepted = true;
+                    if (fn && mod.data !== undefined) {
+                        fn(mod.data);
+                    }
+                }
+            },
+            // Registers fn to be called with a data object just before the
+            // running module is replaced or removed so that it may clean up
+            // and save any state the new version should restore.
+            dispose: function(fn) {
+                let mod = currentModule('dispose');
+                if (mod) {
+                    mod.disposers.push(fn);
+                }
+            }
+        };
+    }
+
+    // Hot module state keyed by the path of the module's file.
+    let modules = {};
+
+    function currentModule(caller) {
+        let script = document.currentScript;
+        let path   = script ? script.getAttribute('data-gooey-file') : null;
+        if (path === null || !modules.hasOwnProperty(path)) {
+            console.error('[GOOEY] gooey.hot.' + caller + ' must be called from the top level of a hot reloaded file.');
+            return undefined;
+        }
+        return modules[path];
+    }
+
+    // Calls the dispose handlers of a module and returns the data they saved.
+    function disposeModule(path) {
+        let data = {};
+        if (modules.hasOwnProperty(path)) {
+            modules[path].disposers.forEach(function(fn) { fn(data); });
+            delete modules[path];
+        }
+        return data;
+    }
+
+    function navigate() {
+        let page = window.location.pathname + window.location.search;
+        if (socket.readyState === OPEN) {
+            socket.send(JSON.stringify({GooeyMessage: 'gooey-client-navigate', GooeyContent: page}));
+        } else {
+            gooey.OnNavigate(page);
+        }
+    }
+
+    // The back and forward buttons move between the pages of gooey.Navigate.
+    window.addEventListener('popstate', function() {
+        navigate();
+    });
+
+    let timeoutID = window.setInterval(function () {
+        if (socket.readyState === CLOSED) {
+            window.clearInterval(timeoutID);
+            gooey.IsDisconnected = true;
+            gooey.OnDisconnect();
+        }
+    }, 1500);
+
+    // When the server is run by the gooey dev command it is restarted on every
+    // rebuild.  Once the server is back the page is refreshed.
+    let devMode = false;
+
+    socket.addEventListener('close', function(evt) {
+        if (!devMode && evt.code !== 1012) {
+            return;
+        }
+        console.log('[GOOEY] Server is restarting, waiting to reconnect.');
+        let retryID = window.setInterval(function() {
+            let req = new XMLHttpRequest();
+            req.open('GET', window.location.href, true);
+            req.onload = function() {
+                window.clearInterval(retryID);
+                window.location.reload();
+            };
+            req.send();
+        }, 500);
+    });
+
+    socket.addEventListener('open', function() {
+        gooey.IsDisconnected = false;
+        gooey.OnOpen();
+    });
+
+    // The hot reloaded CSS and Javascript files keyed by their path, each along
+    // with the <style> or <script> element made from it, and the order of the
+    // files last received from the server.
+    let reloaded = {};
+    let order    = [];
+
+    function reloadElement(file) {
+        let elt = undefined;
+        if (file.Type === 'css') {
+            elt = document.createElement('style');
+            elt.textContent = file.Content + '\n/*# sourceURL=' + file.Path + ' */';
+        } else {
+            elt = document.createElement('script');
+            elt.textContent = file.Content + '\n//# sourceURL=' + file.Path;
+        }
+        elt.setAttribute('data-gooey-file', file.Path);
+        if (nonce) {
+            elt.nonce = nonce;
+        }
+        return elt;
+    }
+
+    // The errors of the last reload are shown in an overlay on the page until
+    // it is dismissed or the next reload succeeds.  The server reports the
+    // files it failed to load and the client adds the errors thrown while
+    // running the reloaded scripts.  When run by the gooey dev command, the
+    // errors of a failed Go build are shown along with them until a build
+    // succeeds.
+    let serverErrors = [];
+    let reloadErrors = [];
+    let buildErrors  = [];
+    let overlay      = undefined;
+
+    function showErrors() {
+        if (overlay) {
+            overlay.remove();
+            overlay = undefined;
+        }
+        let errors = buildErrors.concat(reloadErrors);
+        if (errors.length === 0) {
+            return;
+        }
+
+        // Styles are set through the style property rather than a <style> tag
+        // or attribute to remain allowed under a Content-Security-Policy.
+        overlay = document.createElement('div');
+        overlay.id = 'gooey-error-overlay';
+        let style = overlay.style;
+        style.position   = 'fixed';
+        style.top        = '0';
+        style.left       = '0';
+        style.right      = '0';
+        style.maxHeight  = '50%';
+        style.overflow   = 'auto';
+        style.zIndex     = '2147483647';
+        style.padding    = '12px 16px';
+        style.background = 'rgba(60, 0, 0, 0.92)';
+        style.color      = '#fdd';
+        style.fontFamily = 'monospace';
+        style.fontSize   = '13px';
+        style.whiteSpace = 'pre-wrap';
+
+        let dismiss = document.createElement('button');
+        dismiss.textContent = 'Dismiss';
+        dismiss.style.float = 'right';
+        dismiss.addEventListener('click', function() {
+            buildErrors  = [];
+            reloadErrors = [];
+            showErrors();
+        });
+        overlay.appendChild(dismiss);
+
+        let title = document.createElement('div');
+        title.textContent = buildErrors.length > 0 ? '[GOOEY] Build failed' : '[GOOEY] Reload failed';
+        title.style.fontWeight   = 'bold';
+        title.style.marginBottom = '8px';
+        overlay.appendChild(title);
+
+        errors.forEach(function(err) {
+            let line = document.createElement('div');
+            line.textContent = err.File + (err.Line ? ':' + err.Line : '') + ' -- ' + err.Message;
+            overlay.appendChild(line);
+        });
+        document.body.appendChild(overlay);
+    }
+
+    function reloadContent(cnt) {
+        let changed = {};
+
+        (cnt.Events || []).forEach(function(evt) {
+            gooey.OnReload(evt);
+        });
+        if (cnt.Reload) {
+            window.location.reload();
+            return;
+        }
+
+        if (cnt.Body !== "") {
+            // Every script is run again so it can act on the new body.
+            Object.keys(reloaded).forEach(function(path) {
+                if (reloaded[path].file.Type === 'js') {
+                    changed[path] = reloaded[path].file;
+                }
+            });
+        }
+
+        let removed = [];
+        (cnt.Files || []).forEach(function(file) {
+            if (file.Removed) {
+                removed.push(file.Path);
+                delete changed[file.Path];
+            } else {
+                changed[file.Path] = file;
+            }
+        });
+
+        // A script that is already running can only be replaced or removed if
+        // it accepts hot replacement, otherwise the whole page is reloaded.
+        let replaced = Object.keys(changed).concat(removed).filter(function(path) {
+            return reloaded.hasOwnProperty(path) && reloaded[path].file.Type === 'js';
+        });
+        let reload = replaced.some(function(path) {
+            return !modules.hasOwnProperty(path) || !modules[path].accepted;
+        });
+        if (reload) {
+            window.location.reload();
+            return;
+        }
+
+        let data = {};
+        replaced.forEach(function(path) {
+            data[path] = disposeModule(path);
+        });
+        removed.forEach(function(path) {
+            if (reloaded.hasOwnProperty(path)) {
+                reloaded[path].element.remove();
+                delete reloaded[path];
+            }
+        });
+
+        if (cnt.Errors) {
+            serverErrors = cnt.Errors;
+        }
+        if (cnt.Body !== "") {
+            document.body.innerHTML = cnt.Body;
+        }
+        if (cnt.Order) {
+            order = cnt.Order;
+        }
+
+        // Errors thrown by a script as it runs are reported to window's error
+        // handlers while the script's element is being appended.
+        let scriptErrors = [];
+        let running      = undefined;
+        let onError      = function(evt) {
+            if (running !== undefined) {
+                scriptErrors.push({File: running, Line: evt.lineno, Message: evt.message});
+            }
+        };
+        window.addEventListener('error', onError);
+
+        // Unlike a style tag, we can't just replace the content of a script tag
+        // and have it run again.  Instead, a changed file gets a new element.
+        // Appending the elements in order runs the new scripts in that order
+        // and only moves the unchanged elements, which doesn't run them again.
+        order.forEach(function(path) {
+            let entry = reloaded[path];
+            if (changed.hasOwnProperty(path)) {
+                if (entry) {
+                    entry.element.remove();
+                }
+                entry = {file: changed[path], element: reloadElement(changed[path])};
+                reloaded[path] = entry;
+                if (entry.file.Type === 'js') {
+                    modules[path] = {accepted: false, disposers: [], data: data[path]};
+                }
+            }
+            if (entry) {
+                running = path;
+                document.head.appendChild(entry.element);
+                running = undefined;
+            }
+            if (modules.hasOwnProperty(path)) {
+                delete modules[path].data;
+            }
+        });
+
+        window.removeEventListener('error', onError);
+        reloadErrors = serverErrors.concat(scriptErrors);
+        showErrors();
+    }
+
+    socket.addEventListener('message', function(wsevt) {
+        let data     = JSON.parse(wsevt.data);
+        let isGooey  = (data !== null && typeof data === 'object' &&
+                        data.hasOwnProperty('GooeyMessage') &&
+                        data.hasOwnProperty('GooeyContent'));
+        let doReload = isGooey && data.GooeyMessage === 'gooey-server-reload-content';
+
+        if (isGooey && data.GooeyMessage === 'gooey-server-dev') {
+            devMode = true;
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-navigate') {
+            if (data.GooeyContent.Body !== '') {
+                document.body.innerHTML = data.GooeyContent.Body;
+            }
+            gooey.OnNavigate(data.GooeyContent.Page);
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-build-errors') {
+            buildErrors = data.GooeyContent;
+            showErrors();
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-error') {
+            gooey.OnError(data.GooeyContent);
+        } else if (doReload) {
+            reloadContent(data.GooeyContent);
+        } else {
+            gooey.OnMessage(data);
+        }
+    });
+})();
+`
+
+// The gooey client as an ES module that exports the gooey object as its default
+// export along with its version.
+const CLIENT_MJS = `// The gooey client as an ES module, generated from gooey.js.
+const CONNECTING = 0;
+const OPEN       = 1;
+const CLOSING    = 2;
+const CLOSED     = 3;
+
+// A page opened in a named window carries the window's name in its URL, which
+// is kept as the name of the browser window so it outlives the page.  The
+// server is told the window's name along with the page's URL.
+let params = new URLSearchParams(window.location.search);
+if (params.has('gooeywindow')) {
+    window.name = params.get('gooeywindow');
+}
+
+let scheme   = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+let socket   = new WebSocket(scheme + window.location.host + '/gooeywebsocket' +
+                             '?window=' + encodeURIComponent(window.name) +
+                             '&page=' + encodeURIComponent(window.location.pathname + window.location.search));
+let gooey    = undefined;
+
+// Dynamically created <script> and <style> elements must carry the nonce
+// of the page to be allowed under a Content-Security-Policy.  There is no
+// current script when run as a module so the nonce is taken from any script
+// of the page instead.
+let nonceScript = document.currentScript || document.querySelector('script[nonce]');
+let nonce       = nonceScript ? nonceScript.nonce : '';
+
+// Refer to gooey instead of window.gooey for better minification.
+if (window.hasOwnProperty("gooey")) {
+    gooey = window.gooey;
+} else {
+    gooey = {};
+    window.gooey = gooey;
+
+    gooey.OnMessage = function(msg) { console.log(msg); };
+    gooey.OnReload = function(evt) {
+        console.log('[GOOEY] Reload event ' + evt.Event + ' for ' + evt.Path);
+    };
+    gooey.OnNavigate = function(page) {
+        console.log('[GOOEY] Navigated to ' + page);
+    };
+    gooey.OnError = function(err) {
+        console.error('[GOOEY] ' + err.Code + ': ' + err.Message);
+    };
+    gooey.Send = function(payload) {
+        if (socket.readyState === OPEN) {
+            socket.send(JSON.stringify(payload));
+        } else {
+            console.error('[GOOEY] Websocket connection is not open.');
+        }
+    };
+    gooey.IsDisconnected = false;
+    gooey.OnOpen = function() {
+        console.log('[GOOEY] Websocket connection is open.');
+    };
+    gooey.OnDisconnect = function() {
+        console.error('[GOOEY] Disconnected from server.');
+    };
+    gooey.OpenNewTab = function() {
+        let req = new XMLHttpRequest();
+        req.open('GET', window.location.origin + '/gooeynewtab', true);
+        req.send();
+    };
+    // Changes the page's URL to path, which may include a query, without
+    // loading a new page or closing the connection to the server.  The
+    // server starts the App of the path's route, replacing the body of the
+    // page if the route's page differs, and then gooey.OnNavigate is called.
+    gooey.Navigate = function(path) {
+        window.history.pushState(null, '', path);
+        navigate();
+    };
+    // Opens the page at path, with the query parameters in the params
+    // object, in the browser window called name.  If a window of that name
+    // is open then the page replaces its current one.  If the browser blocks
+    // the window from opening then the server opens it instead.
+    gooey.OpenWindow = function(name, path, params) {
+        let query = new URLSearchParams(params || {});
+        let url   = new URL(path, window.location.origin);
+        query.forEach(function(value, key) {
+            url.searchParams.append(key, value);
+        });
+        url.searchParams.set('gooeywindow', name);
+
+        if (window.open(url.toString(), name) === null) {
+            let req = new XMLHttpRequest();
+            req.open('GET', window.location.origin + '/gooeynewtab' +
+                     '?window=' + encodeURIComponent(name) +
+                     '&path=' + encodeURIComponent(path) +
+                     '&query=' + encodeURIComponent(query.toString()), true);
+            req.send();
+        }
+    };
+
+    // Hot module replacement for the Javascript files hot reloaded from the
+    // server's ReloadWatchDir.  Each file is a module and when a module
+    // changes the page is reloaded unless the module has called accept,
+    // in which case the module's dispose handlers are called and then the
+    // new version of the module is run in place of the old one.  Both
+    // functions must be called while the module's top level code runs.
+    gooey.hot = {
+        // Declares that the running module can be replaced.  When the
+        // module is run as the replacement of an earlier version then fn
+        // is called with the data object filled by the dispose handlers.
+        accept: function(fn) {
+            let mod = currentModule('accept');
+            if (mod) {
+                mod.accepted = true;
+                if (fn && mod.data !== undefined) {
+                    fn(mod.data);
+                }
+            }
+        },
+        // Registers fn to be called with a data object just before the
+        // running module is replaced or removed so that it may clean up
+        // and save any state the new version should restore.
+        dispose: function(fn) {
+            let mod = currentModule('dispose');
+            if (mod) {
+                mod.disposers.push(fn);
+            }
+        }
+    };
+}
+
+// Hot module state keyed by the path of the module's file.
+let modules = {};
+
+function currentModule(caller) {
+    let script = document.currentScript;
+    let path   = script ? script.getAttribute('data-gooey-file') : null;
+    if (path === null || !modules.hasOwnProperty(path)) {
+        console.error('[GOOEY] gooey.hot.' + caller + ' must be called from the top level of a hot reloaded file.');
+        return undefined;
+    }
+    return modules[path];
+}
+
+// Calls the dispose handlers of a module and returns the data they saved.
+function disposeModule(path) {
+    let data = {};
+    if (modules.hasOwnProperty(path)) {
+        modules[path].disposers.forEach(function(fn) { fn(data); });
+        delete modules[path];
+    }
+    return data;
+}
+
+function navigate() {
+    let page = window.location.pathname + window.location.search;
+    if (socket.readyState === OPEN) {
+        socket.send(JSON.stringify({GooeyMessage: 'gooey-client-navigate', GooeyContent: page}));
+    } else {
+        gooey.OnNavigate(page);
+    }
+}
+
+// The back and forward buttons move between the pages of gooey.Navigate.
+window.addEventListener('popstate', function() {
+    navigate();
+});
+
+let timeoutID = window.setInterval(function () {
+    if (socket.readyState === CLOSED) {
+        window.clearInterval(timeoutID);
+        gooey.IsDisconnected = true;
+        gooey.OnDisconnect();
+    }
+}, 1500);
+
+// When the server is run by the gooey dev command it is restarted on every
+// rebuild.  Once the server is back the page is refreshed.
+let devMode = false;
+
+socket.addEventListener('close', function(evt) {
+    if (!devMode && evt.code !== 1012) {
+        return;
+    }
+    console.log('[GOOEY] Server is restarting, waiting to reconnect.');
+    let retryID = window.setInterval(function() {
+        let req = new XMLHttpRequest();
+        req.open('GET', window.location.href, true);
+        req.onload = function() {
+            window.clearInterval(retryID);
+            window.location.reload();
+        };
+        req.send();
+    }, 500);
+});
+
+socket.addEventListener('open', function() {
+    gooey.IsDisconnected = false;
+    gooey.OnOpen();
+});
+
+// The hot reloaded CSS and Javascript files keyed by their path, each along
+// with the <style> or <script> element made from it, and the order of the
+// files last received from the server.
+let reloaded = {};
+let order    = [];
+
+function reloadElement(file) {
+    let elt = undefined;
+    if (file.Type === 'css') {
+        elt = document.createElement('style');
+        elt.textContent = file.Content + '\n/*# sourceURL=' + file.Path + ' */';
+    } else {
+        elt = document.createElement('script');
+        elt.textContent = file.Content + '\n//# sourceURL=' + file.Path;
+    }
+    elt.setAttribute('data-gooey-file', file.Path);
+    if (nonce) {
+        elt.nonce = nonce;
+    }
+    return elt;
+}
+
+// The errors of the last reload are shown in an overlay on the page until
+// it is dismissed or the next reload succeeds.  The server reports the
+// files it failed to load and the client adds the errors thrown while
+// running the reloaded scripts.  When run by the gooey dev command, the
+// errors of a failed Go build are shown along with them until a build
+// succeeds.
+let serverErrors = [];
+let reloadErrors = [];
+let buildErrors  = [];
+let overlay      = undefined;
+
+function showErrors() {
+    if (overlay) {
+        overlay.remove();
+        overlay = undefined;
+    }
+    let errors = buildErrors.concat(reloadErrors);
+    if (errors.length === 0) {
+        return;
+    }
+
+    // Styles are set through the style property rather than a <style> tag
+    // or attribute to remain allowed under a Content-Security-Policy.
+    overlay = document.createElement('div');
+    overlay.id = 'gooey-error-overlay';
+    let style = overlay.style;
+    style.position   = 'fixed';
+    style.top        = '0';
+    style.left       = '0';
+    style.right      = '0';
+    style.maxHeight  = '50%';
+    style.overflow   = 'auto';
+    style.zIndex     = '2147483647';
+    style.padding    = '12px 16px';
+    style.background = 'rgba(60, 0, 0, 0.92)';
+    style.color      = '#fdd';
+    style.fontFamily = 'monospace';
+    style.fontSize   = '13px';
+    style.whiteSpace = 'pre-wrap';
+
+    let dismiss = document.createElement('button');
+    dismiss.textContent = 'Dismiss';
+    dismiss.style.float = 'right';
+    dismiss.addEventListener('click', function() {
+        buildErrors  = [];
+        reloadErrors = [];
+        showErrors();
+    });
+    overlay.appendChild(dismiss);
+
+    let title = document.createElement('div');
+    title.textContent = buildErrors.length > 0 ? '[GOOEY] Build failed' : '[GOOEY] Reload failed';
+    title.style.fontWeight   = 'bold';
+    title.style.marginBottom = '8px';
+    overlay.appendChild(title);
+
+    errors.forEach(function(err) {
+        let line = document.createElement('div');
+        line.textContent = err.File + (err.Line ? ':' + err.Line : '') + ' -- ' + err.Message;
+        overlay.appendChild(line);
+    });
+    document.body.appendChild(overlay);
+}
+
+function reloadContent(cnt) {
+    let changed = {};
+
+    (cnt.Events || []).forEach(function(evt) {
+        gooey.OnReload(evt);
+    });
+    if (cnt.Reload) {
+        window.location.reload();
+        return;
+    }
+
+    if (cnt.Body !== "") {
+        // Every script is run again so it can act on the new body.
+        Object.keys(reloaded).forEach(function(path) {
+            if (reloaded[path].file.Type === 'js') {
+                changed[path] = reloaded[path].file;
+            }
+        });
+    }
+
+    let removed = [];
+    (cnt.Files || []).forEach(function(file) {
+        if (file.Removed) {
+            removed.push(file.Path);
+            delete changed[file.Path];
+        } else {
+            changed[file.Path] = file;
+        }
+    });
+
+    // A script that is already running can only be replaced or removed if
+    // it accepts hot replacement, otherwise the whole page is reloaded.
+    let replaced = Object.keys(changed).concat(removed).filter(function(path) {
+        return reloaded.hasOwnProperty(path) && reloaded[path].file.Type === 'js';
+    });
+    let reload = replaced.some(function(path) {
+        return !modules.hasOwnProperty(path) || !modules[path].accepted;
+    });
+    if (reload) {
+        window.location.reload();
+        return;
+    }
+
+    let data = {};
+    replaced.forEach(function(path) {
+        data[path] = disposeModule(path);
+    });
+    removed.forEach(function(path) {
+        if (reloaded.hasOwnProperty(path)) {
+            reloaded[path].element.remove();
+            delete reloaded[path];
+        }
+    });
+
+    if (cnt.Errors) {
+        serverErrors = cnt.Errors;
+    }
+    if (cnt.Body !== "") {
+        document.body.innerHTML = cnt.Body;
+    }
+    if (cnt.Order) {
+        order = cnt.Order;
+    }
+
+    // Errors thrown by a script as it runs are reported to window's error
+    // handlers while the script's element is being appended.
+    let scriptErrors = [];
+    let running      = undefined;
+    let onError      = function(evt) {
+        if (running !== undefined) {
+            scriptErrors.push({File: running, Line: evt.lineno, Message: evt.message});
+        }
+    };
+    window.addEventListener('error', onError);
+
+    // Unlike a style tag, we can't just replace the content of a script tag
+    // and have it run again.  Instead, a changed file gets a new element.
+    // Appending the elements in order runs the new scripts in that order
+    // and only moves the unchanged elements, which doesn't run them again.
+    order.forEach(function(path) {
+        let entry = reloaded[path];
+        if (changed.hasOwnProperty(path)) {
+            if (entry) {
+                entry.element.remove();
+            }
+            entry = {file: changed[path], element: reloadElement(changed[path])};
+            reloaded[path] = entry;
+            if (entry.file.Type === 'js') {
+                modules[path] = {accepted: false, disposers: [], data: data[path]};
+            }
+        }
+        if (entry) {
+            running = path;
+            document.head.appendChild(entry.element);
+            running = undefined;
+        }
+        if (modules.hasOwnProperty(path)) {
+            delete modules[path].data;
+        }
+    });
+
+    window.removeEventListener('error', onError);
+    reloadErrors = serverErrors.concat(scriptErrors);
+    showErrors();
+}
+
+socket.addEventListener('message', function(wsevt) {
+    let data     = JSON.parse(wsevt.data);
+    let isGooey  = (data !== null && typeof data === 'object' &&
+                    data.hasOwnProperty('GooeyMessage') &&
+                    data.hasOwnProperty('GooeyContent'));
+    let doReload = isGooey && data.GooeyMessage === 'gooey-server-reload-content';
+
+    if (isGooey && data.GooeyMessage === 'gooey-server-dev') {
+        devMode = true;
+    } else if (isGooey && data.GooeyMessage === 'gooey-server-navigate') {
+        if (data.GooeyContent.Body !== '') {
+            document.body.innerHTML = data.GooeyContent.Body;
+        }
+        gooey.OnNavigate(data.GooeyContent.Page);
+    } else if (isGooey && data.GooeyMessage === 'gooey-server-build-errors') {
+        buildErrors = data.GooeyContent;
+        showErrors();
+    } else if (isGooey && data.GooeyMessage === 'gooey-server-error') {
+        gooey.OnError(data.GooeyContent);
+    } else if (doReload) {
+        reloadContent(data.GooeyContent);
+    } else {
+        gooey.OnMessage(data);
+    }
+});
+
+export default gooey;
+export const version = '1ed650e309ae';
+`
+
+// TypeScript declarations of CLIENT_MJS.
+const CLIENT_DTS = `// Type declarations for the gooey client served at /gooey/client.mjs.
+
+/** An error reported by the server, such as a message rejected by MessageRoles. */
+export interface GooeyError {
+    Code: string;
+    Message: string;
+    Type: string;
+}
+
+/** A file change reported by a ReloadRule with the ReloadEvent action. */
+export interface ReloadEvent {
+    Event: string;
+    Path: string;
+    Removed: boolean;
+}
+
+/** Hot module replacement for the Javascript files reloaded from ReloadWatchDir. */
+export interface Hot {
+    /**
+     * Declares that the running module can be replaced.  When the module is run
+     * as the replacement of an earlier version then fn is called with the data
+     * filled by the dispose handlers.  Must be called from the module's top level.
+     */
+    accept(fn?: (data: Record<string, unknown>) => void): void;
+
+    /**
+     * Registers fn to be called with a data object just before the running
+     * module is replaced or removed.  Must be called from the module's top level.
+     */
+    dispose(fn: (data: Record<string, unknown>) => void): void;
+}
+
+export interface Gooey {
+    /** Called with every message the App sends.  Override to handle messages. */
+    OnMessage(msg: any): void;
+
+    /** Called for every file change event of a hot reload. */
+    OnReload(evt: ReloadEvent): void;
+
+    /** Called with the path and query of the page after gooey.Navigate. */
+    OnNavigate(page: string): void;
+
+    /** Called when the server rejects a message. */
+    OnError(err: GooeyError): void;
+
+    /** Called once the websocket connection to the server is open. */
+    OnOpen(): void;
+
+    /** Called once the websocket connection to the server is lost. */
+    OnDisconnect(): void;
+
+    /** Sends payload, encoded as JSON, to the App. */
+    Send(payload: unknown): void;
+
+    /** Whether the connection to the server has been lost. */
+    IsDisconnected: boolean;
+
+    /** Has the server open another tab onto the app. */
+    OpenNewTab(): void;
+
+    /** Opens the page at path with the params as its query in the window called name. */
+    OpenWindow(name: string, path: string, params?: Record<string, string>): void;
+
+    /** Changes the page's URL to path, and so its route, without closing the connection. */
+    Navigate(path: string): void;
+
+    hot: Hot;
+}
+
+declare const gooey: Gooey;
+export default gooey;
+
+/** The version of the client, which changes whenever the client does. */
+export declare const version: string;
+
+declare global {
+    interface Window {
+        gooey: Gooey;
+    }
+}
+`
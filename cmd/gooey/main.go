@@ -0,0 +1,240 @@
+// Command gooey provides tools for developing gooey apps.
+//
+// The dev sub command rebuilds and restarts a gooey app whenever one of its Go
+// source files changes:
+//
+//	gooey dev [-addr 127.0.0.1:8080] [-dir .] [-tls] [-no-open] [-- ARGS...]
+//
+// The package in dir is built and run with ARGS and is given the fixed address
+// to listen on through the GOOEY_DEV_ADDR environment variable, which has the
+// gooey Server listen on that address, not open a browser tab, and stay up when
+// the last tab is closed.  The dev command opens a browser tab once the app
+// first starts, using the browser named by $BROWSER if it is set.  Whenever a
+// .go file, go.mod, or go.sum within dir changes the package is rebuilt and, if
+// the build succeeds, the running app is interrupted and the new build is started
+// in its place.  Open tabs wait for the new build to start listening and then
+// refresh themselves.  If the build fails then the errors are printed and the
+// previous build keeps running, and the errors are shown in an overlay on the
+// app's open tabs until a build succeeds.
+//
+// For the app to shut down cleanly it should close the done channel given to
+// Server.Start when it receives an interrupt signal, as the example in the
+// gooey package documentation does.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/0xABAD/filewatch"
+	"github.com/0xABAD/gooey"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gooey: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "dev":
+		dev(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, "Unrecognized command:", os.Args[1])
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "USAGE: gooey dev [-addr ADDR] [-dir DIR] [-tls] [-no-open] [-- ARGS...]")
+	os.Exit(2)
+}
+
+func dev(args []string) {
+	var (
+		flags  = flag.NewFlagSet("dev", flag.ExitOnError)
+		addr   = flags.String("addr", "127.0.0.1:8080", "the fixed address the app listens on")
+		dir    = flags.String("dir", ".", "the directory of the app's main package")
+		useTLS = flags.Bool("tls", false, "open the browser with https since the app serves over TLS")
+		noOpen = flags.Bool("no-open", false, "don't open a browser tab when the app first starts")
+	)
+	flags.Parse(args)
+
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		log.Fatalln("Failed to find directory", *dir, "--", err)
+	}
+
+	tmp, err := ioutil.TempDir("", "gooey_dev")
+	if err != nil {
+		log.Fatalln("Failed to create temporary build directory --", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// Each build gets its own executable since Windows won't let the executable of
+	// the running app be replaced.
+	builds := 0
+	nextExe := func() string {
+		builds++
+		exe := filepath.Join(tmp, fmt.Sprintf("app%d", builds))
+		if runtime.GOOS == "windows" {
+			exe += ".exe"
+		}
+		return exe
+	}
+	buildLog := filepath.Join(tmp, "build.log")
+
+	var (
+		done     = make(chan struct{})
+		notify   = make(chan os.Signal, 1)
+		interval = 500 * time.Millisecond
+	)
+	signal.Notify(notify, os.Interrupt, syscall.SIGTERM)
+
+	updates, err := filewatch.Watch(done, root, true, &interval)
+	if err != nil {
+		log.Fatalln("Failed to watch", root, "--", err)
+	}
+	<-updates // The initial update lists every file.
+
+	var (
+		app *exec.Cmd
+		exe = nextExe()
+	)
+	if build(root, exe, buildLog) {
+		app = run(exe, *addr, buildLog, flags.Args())
+		if app != nil && !*noOpen {
+			scheme := "http"
+			if *useTLS {
+				scheme = "https"
+			}
+			go openBrowser(scheme+"://"+*addr, *addr)
+		}
+	}
+
+	for {
+		select {
+		case <-notify:
+			stop(app)
+			close(done)
+			return
+
+		case us := <-updates:
+			if !sourcesChanged(us) {
+				continue
+			}
+			log.Println("Rebuilding", root)
+			next := nextExe()
+			if !build(root, next, buildLog) {
+				continue
+			}
+			stop(app)
+			os.Remove(exe)
+			exe = next
+			app = run(exe, *addr, buildLog, flags.Args())
+		}
+	}
+}
+
+// Reports whether any of the updates is to a file that affects the build.
+func sourcesChanged(updates []filewatch.Update) bool {
+	for _, u := range updates {
+		name := filepath.Base(u.AbsPath)
+		if strings.HasSuffix(name, ".go") || name == "go.mod" || name == "go.sum" {
+			return true
+		}
+	}
+	return false
+}
+
+// Builds the package in dir to the executable exe and reports whether the build
+// succeeded.  The output of a failed build is written to buildLog, for the running
+// app to show, and buildLog is removed once a build succeeds.
+func build(dir, exe, buildLog string) bool {
+	var out bytes.Buffer
+	cmd := exec.Command("go", "build", "-o", exe, ".")
+	cmd.Dir = dir
+	cmd.Stdout = io.MultiWriter(os.Stdout, &out)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &out)
+	if err := cmd.Run(); err != nil {
+		log.Println("Build failed --", err)
+		if out.Len() == 0 {
+			out.WriteString(err.Error())
+		}
+		if err := ioutil.WriteFile(buildLog, out.Bytes(), 0600); err != nil {
+			log.Println("Failed to write build log --", err)
+		}
+		return false
+	}
+	os.Remove(buildLog)
+	return true
+}
+
+// Starts the executable with the dev address and build log set in its environment.
+func run(exe, addr, buildLog string, args []string) *exec.Cmd {
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), gooey.DevAddrEnv+"="+addr, gooey.DevBuildLogEnv+"="+buildLog)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Println("Failed to start app --", err)
+		return nil
+	}
+	return cmd
+}
+
+// Interrupts the running app so that it may shut down cleanly and kills it if
+// it hasn't exited after a few seconds.
+func stop(app *exec.Cmd) {
+	if app == nil || app.Process == nil {
+		return
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		app.Wait()
+		close(exited)
+	}()
+
+	if err := app.Process.Signal(os.Interrupt); err != nil {
+		app.Process.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		log.Println("App did not exit after an interrupt, killing it")
+		app.Process.Kill()
+		<-exited
+	}
+}
+
+// Opens a browser tab at url once the app is listening on addr.
+func openBrowser(url, addr string) {
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			if err := (gooey.DefaultBrowser{}).Launch(url); err != nil {
+				log.Println("Failed to open browser --", err)
+			}
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Println("App never started listening on", addr)
+}
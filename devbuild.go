@@ -0,0 +1,110 @@
+package gooey
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DevBuildLogEnv is the environment variable, set by the gooey dev command, that
+// names the file the command writes the output of a failed build to.  The file is
+// removed once a build succeeds.  While the file exists a Server started by the
+// command shows its errors in the error overlay of every open page, see
+// ReloadWatchDir, since the app keeps running the previous build.
+const DevBuildLogEnv = "GOOEY_DEV_BUILD_LOG"
+
+// A line of the go command's output that gives the position of an error.
+var buildErrorLine = regexp.MustCompile(`^(.+?\.go):(\d+)(?::\d+)?: (.*)$`)
+
+// Polls the build log named by DevBuildLogEnv until done is closed and sends its
+// errors to every client whenever the log changes.
+func (s *Server) watchBuildLog(done <-chan struct{}, path string) {
+	var (
+		ticker  = time.NewTicker(250 * time.Millisecond)
+		modTime time.Time
+		size    int64 = -1
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if size == -1 {
+				continue
+			}
+			size = -1
+			s.setBuildErrors([]reloadError{})
+			continue
+		}
+		if info.ModTime().Equal(modTime) && info.Size() == size {
+			continue
+		}
+		modTime, size = info.ModTime(), info.Size()
+
+		out, err := ioutil.ReadFile(path)
+		if err != nil {
+			s.errorln("Failed to read build log --", err)
+			continue
+		}
+		s.setBuildErrors(parseBuildErrors(string(out)))
+	}
+}
+
+// Records the current build errors and sends them to every connected client.
+func (s *Server) setBuildErrors(errs []reloadError) {
+	s.mu.Lock()
+	s.buildErrs = errs
+	targets := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		targets = append(targets, c)
+	}
+	s.mu.Unlock()
+
+	msg := gooeyMessage{"gooey-server-build-errors", errs}
+	for _, c := range targets {
+		select {
+		case c.direct <- msg:
+		case <-c.gone:
+		}
+	}
+}
+
+// Returns the current build errors, or nil if the last build succeeded.
+func (s *Server) buildErrors() []reloadError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buildErrs
+}
+
+// Returns the errors in the output of a failed go build.  If no line of the output
+// gives the position of an error then the whole output is the one error.
+func parseBuildErrors(out string) []reloadError {
+	errs := []reloadError{}
+	for _, line := range strings.Split(out, "\n") {
+		m := buildErrorLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		errs = append(errs, reloadError{
+			File:    strings.TrimPrefix(m[1], "./"),
+			Line:    n,
+			Message: m[3],
+		})
+	}
+	if len(errs) == 0 {
+		if out = strings.TrimSpace(out); out != "" {
+			errs = append(errs, reloadError{File: "go build", Message: out})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,80 @@
+// Type declarations for the gooey client served at /gooey/client.mjs.
+
+/** An error reported by the server, such as a message rejected by MessageRoles. */
+export interface GooeyError {
+    Code: string;
+    Message: string;
+    Type: string;
+}
+
+/** A file change reported by a ReloadRule with the ReloadEvent action. */
+export interface ReloadEvent {
+    Event: string;
+    Path: string;
+    Removed: boolean;
+}
+
+/** Hot module replacement for the Javascript files reloaded from ReloadWatchDir. */
+export interface Hot {
+    /**
+     * Declares that the running module can be replaced.  When the module is run
+     * as the replacement of an earlier version then fn is called with the data
+     * filled by the dispose handlers.  Must be called from the module's top level.
+     */
+    accept(fn?: (data: Record<string, unknown>) => void): void;
+
+    /**
+     * Registers fn to be called with a data object just before the running
+     * module is replaced or removed.  Must be called from the module's top level.
+     */
+    dispose(fn: (data: Record<string, unknown>) => void): void;
+}
+
+export interface Gooey {
+    /** Called with every message the App sends.  Override to handle messages. */
+    OnMessage(msg: any): void;
+
+    /** Called for every file change event of a hot reload. */
+    OnReload(evt: ReloadEvent): void;
+
+    /** Called with the path and query of the page after gooey.Navigate. */
+    OnNavigate(page: string): void;
+
+    /** Called when the server rejects a message. */
+    OnError(err: GooeyError): void;
+
+    /** Called once the websocket connection to the server is open. */
+    OnOpen(): void;
+
+    /** Called once the websocket connection to the server is lost. */
+    OnDisconnect(): void;
+
+    /** Sends payload, encoded as JSON, to the App. */
+    Send(payload: unknown): void;
+
+    /** Whether the connection to the server has been lost. */
+    IsDisconnected: boolean;
+
+    /** Has the server open another tab onto the app. */
+    OpenNewTab(): void;
+
+    /** Opens the page at path with the params as its query in the window called name. */
+    OpenWindow(name: string, path: string, params?: Record<string, string>): void;
+
+    /** Changes the page's URL to path, and so its route, without closing the connection. */
+    Navigate(path: string): void;
+
+    hot: Hot;
+}
+
+declare const gooey: Gooey;
+export default gooey;
+
+/** The version of the client, which changes whenever the client does. */
+export declare const version: string;
+
+declare global {
+    interface Window {
+        gooey: Gooey;
+    }
+}
@@ -1,25 +1,23 @@
 package gooey
 
 import (
-	"bytes"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
-	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/0xABAD/filewatch"
 	"github.com/gorilla/websocket"
 )
 
@@ -45,6 +43,64 @@ type App interface {
 	Start(closed <-chan struct{}, incoming <-chan []byte, outgoing chan<- interface{})
 }
 
+// SessionApp is an App that wishes to know about the client of each connection.  If
+// the App given to Server.Start also implements SessionApp then StartSession will be
+// called in place of Start for each connecting client, with the same semantics as
+// Start along with a description of the connection's session.
+type SessionApp interface {
+	StartSession(session *Session, closed <-chan struct{}, incoming <-chan []byte, outgoing chan<- interface{})
+}
+
+// Lifecycle is an App that wishes to know about the life of the server as a whole
+// rather than only of each connection.  If the App given to Server.Start also
+// implements Lifecycle then its methods are called as follows.
+//
+// ServerReady is called once the server is listening at url but before any client
+// connection is accepted, allowing the App to initialize resources shared by all
+// connections.
+//
+// FirstClientConnected is called when a client connects while no other client is
+// connected, with the session of that client.  LastClientLeft is called once the
+// last client has disconnected and no client has connected again within the
+// ShutdownGracePeriod, so that refreshing the page doesn't call either method.
+// Both are called even when NoAutoShutdown is set.
+//
+// ShuttingDown is called when the server shuts down, for any reason, and the call
+// to Server.Start will not return until ShuttingDown does, allowing the App to
+// persist its state.
+//
+// All methods besides ShuttingDown are called on the goroutine that monitors the
+// server's connections, so they should return quickly.
+type Lifecycle interface {
+	ServerReady(url string)
+	FirstClientConnected(session *Session)
+	LastClientLeft()
+	ShuttingDown()
+}
+
+// Session describes a single client connection to a gooey Server.
+type Session struct {
+	// The identity of the user that opened the connection as given by the server's
+	// Authenticator.  If the server has no Authenticator then this field is nil.
+	Identity *Identity
+
+	// The remote network address of the client.
+	RemoteAddr string
+
+	// The name of the browser window the client is connected from, as given to
+	// Server.OpenWindow or gooey.OpenWindow, or the empty string for a window that
+	// wasn't opened by name, such as the window opened when the server starts.
+	Window string
+
+	// The path of the client's page, which selects the route of the server's Routes
+	// whose App handles the connection.
+	Path string
+
+	// The query parameters of the client's page, such as the params given to
+	// Server.OpenWindow, without the parameters used by gooey itself.
+	Params url.Values
+}
+
 // Server represents an active server connection that can listen to incoming connecting
 // clients.
 type Server struct {
@@ -54,6 +110,21 @@ type Server struct {
 	// the OS to select a random port for the connection.
 	Addr string
 
+	// The network of Addr, as accepted by net.Listen, which is "tcp" if Network is the
+	// empty string.  With "unix", Addr is the path of a Unix domain socket, which lets
+	// filesystem permissions control who can connect, and on Linux an Addr starting
+	// with "@" names an abstract socket.  The server can then be reached through an
+	// SSH tunnel or a local reverse proxy.  Addr must be set for a "unix" network.  A
+	// browser is never launched for a server that isn't listening on TCP and its URL,
+	// as given to ReadyC, has the form "http+unix://PATH/" with the path escaped.
+	Network string
+
+	// If non nil then the server serves on Listener in place of listening on Network
+	// and Addr, and the server closes Listener when Start returns.  When run by the
+	// gooey dev command the server listens on the command's address instead and
+	// Listener is closed without being served on.
+	Listener net.Listener
+
 	// The directory of where Server should serve web files from.  If WebServeDir is
 	// the empty string then Server will serve files from a created temporary directory.
 	// Server will generate index.html and favicon.ico files, dependent on the values
@@ -97,28 +168,155 @@ type Server struct {
 	//       * Set ForceIndexAndFavIcon to true.
 	ForceIndexAndFavIcon bool
 
+	// Maps paths of the server, such as "/logs", to the page served at the path and
+	// the App that handles the connections made from that page.  A path must match a
+	// request's path exactly, and connections from pages that match no route are
+	// handled by the App given to Start.  Calling gooey.Navigate in gooey.js changes
+	// the page's URL with history.pushState while keeping the connection open.  If
+	// the new URL is of another route then the App of the old route is stopped, as if
+	// its connection had closed, and the App of the new route is started on the same
+	// connection, and if the new route's page differs from the old one then the
+	// page's body is replaced with that of the new page.  Note that the scripts of
+	// the new body are not run, so pages should react to gooey.OnNavigate instead.
+	// The navigation messages of gooey.js are handled by the server itself: they
+	// count towards MessageRate but are never passed to an App and are not checked
+	// against MessageRoles, since every route's page can be requested anyway.
+	Routes map[string]Route
+
+	// A TLS configuration for the server to serve its content and websocket over
+	// HTTPS and WSS.  If this field is non nil then it takes precedence over the
+	// CertFile, KeyFile, and SelfSignedTLS fields.  The configuration must contain
+	// at least one certificate or set GetCertificate.
+	TLSConfig *tls.Config
+
+	// Paths to a PEM encoded certificate and its matching private key.  If either
+	// is set (and TLSConfig is nil) then the server will serve over HTTPS with the
+	// loaded key pair.  Failure to load the pair is an initialization error.
+	CertFile, KeyFile string
+
+	// Set SelfSignedTLS to true to have the server generate a self-signed certificate
+	// on start and serve over HTTPS.  This is intended for dashboards reached over a
+	// LAN where a proper certificate is unavailable.  Browsers will warn about the
+	// certificate on the first visit.  The certificate is valid for localhost and the
+	// host portion of Addr, whether it is a name such as "mybox.local" or an IP
+	// address, along with the IP address the name resolves to.  If Addr listens on all
+	// interfaces (e.g. "0.0.0.0:8080") then it is valid for the machine's host name
+	// and the addresses of every network interface on the machine.
+	// This field is ignored if TLSConfig, CertFile, or KeyFile is set.
+	SelfSignedTLS bool
+
+	// If non nil then every HTTP request to the server, including requests for static
+	// files, new tabs, and the websocket upgrade, is authenticated by Authenticator
+	// before it is handled.  Requests that fail authentication are given to the
+	// Authenticator's Challenge method.  The identity of the user is made available
+	// to Apps that implement SessionApp.  If the Authenticator also implements
+	// http.Handler, as FormAuth does, then it will handle requests to /gooeylogin
+	// and /gooeylogout without those requests being authenticated.
+	Authenticator Authenticator
+
+	// The origins, such as "https://example.com", besides the server's own that may
+	// open the websocket when the server has an Authenticator.  Without an
+	// Authenticator any origin may connect, as before, but with one the websocket
+	// upgrade carries the user's credentials, such as a basic auth password or an SSO
+	// cookie, so a page of another site could otherwise drive the App as the user.
+	AllowedOrigins []string
+
+	// Maps the type of an incoming client message to the roles, any one of which,
+	// the session's Identity must have for the message to be passed on to the App.
+	// Messages of a type not in the map are always passed on unless the map has an
+	// entry for "*", which then applies to every type not explicitly listed.  A type
+	// listed with no roles is passed on for every session, even with a "*" entry.  A
+	// rejected message never reaches the App's incoming channel; instead, it is
+	// reported through ErrorLog and ErrorC and an error is sent back to the client
+	// which can be received by overriding the gooey.OnError function in gooey.js.
+	// If the server has no Authenticator then the session has no roles and only
+	// messages whose type maps to no roles are allowed.
+	MessageRoles map[string][]string
+
+	// Returns the type of an incoming message for checking it against MessageRoles.
+	// If nil then the message is expected to be a JSON object and its type is the
+	// value of its Type field or, if that is missing, its Method field.  Messages
+	// that are not JSON objects have the empty string as their type.  Since Apps may
+	// decode messages without regard to the case of keys, or dispatch on either
+	// field, a message is rejected without reaching the App if it has more than one
+	// key matching type or method when case is ignored, if either isn't a string, or
+	// if its Type and Method are both set and differ.  Set MessageType if the App
+	// reads the type of its messages from elsewhere.
+	MessageType func(msg []byte) string
+
+	// If non nil then the server adds the configured security headers, including a
+	// Content-Security-Policy, to every response.  The policy may use a nonce that
+	// is generated for every request and added to each <script> and <style> tag
+	// of the index page as it is served so that the page's inline content, such as
+	// gooey's embedded client code, is allowed by a strict policy.  The client code
+	// in gooey.js passes the nonce on to the <script> and <style> tags it creates
+	// for hot reloading.  With TemplateData the nonce is added to the tags of the
+	// page's template, not to tags written into the page by the template's data.
+	SecurityHeaders *SecurityHeaders
+
+	// The maximum size, in bytes, of a message a client may send on the websocket.
+	// If a client sends a larger message then the connection is closed with the
+	// 1009 (message too big) close code.  If this field is zero or less then there
+	// is no limit.
+	MaxMessageSize int64
+
+	// The number of messages per second a single client connection may send on
+	// average, with bursts of up to MessageBurst messages.  If a client exceeds the
+	// rate then the connection is closed with the 1008 (policy violation) close
+	// code.  If MessageRate is zero or less then there is no limit and if
+	// MessageBurst is zero or less then the burst is MessageRate rounded up.
+	MessageRate  float64
+	MessageBurst int
+
+	// The maximum number of websocket connections the server will have open at one
+	// time.  Connections beyond the limit are closed immediately with the 1013 (try
+	// again later) close code.  If this field is zero or less then there is no limit.
+	MaxConnections int
+
 	// Specifies a directory whose contents will be watched (recursively) for changes and
 	// when a change is detected then a special message will be sent to the client to
 	// reload the page contents.  If this field is the empty string then no hot reloading
-	// will occur.
+	// will occur.  The directory is scanned when the server starts and is then watched
+	// once for the server with every change sent to all connected clients.  A newly
+	// connected client is immediately sent the current content of the directory, that
+	// is the body.html file along with all the CSS and Javascript, following the rules
+	// below.
 	//
 	// There are special rules for which files be sent for the hot reload.  First, if
 	// one of the files that has changed is named body.html then the contents of that
 	// file will replace the body of the current document loaded in the client.  If body.html
 	// is removed then the body of the document will be replaced with an empty <div> tag.
-	// If a CSS or a Javascript file is added or changed then all known CSS or Javascript
-	// content will be concatenated together and replace a gooey specially constructed
-	// <style> or <script> tag that is appended at the end of the document <head> element,
-	// if it exist.  If a CSS or a Javascript file is removed then the contents of the
-	// file will be omitted from the concatenated content.
+	// The body only replaces that of the index page, the page served at "/" or by a
+	// route without a Page of its own, so pages of routes with their own Page and
+	// other pages of WebServeDir keep their body and only reload CSS and Javascript.
+	// Each CSS and Javascript file is given its own <style> or <script> tag that is
+	// appended at the end of the document <head> element and is marked with a
+	// data-gooey-file attribute holding the path of the file relative to ReloadWatchDir.
+	// If a CSS or a Javascript file is added or changed then only the tag of that file
+	// is replaced and if the file is removed then its tag is removed.  The content of
+	// each tag ends with a sourceURL comment naming the file so that the browser's
+	// developer tools show the content under the file's name.  If body.html changes
+	// then every Javascript file is run again against the new body.
 	//
-	// Javascript files have an additional rule that allows enforcement of ordering of
-	// Javascript content that will be embedded in the document.  If a Javascript file has a
-	// number after a dot in the file name but before the .js extenstion then that number
-	// declares the ordering amongst other ordered Javascript files which will also be inserted
-	// before other non ordered watched javascript files.  For example, suppose we have three
-	// Javascript files: baz.js, bar.3.js, and foo.0.js, and suppose the current <head>
-	// tag is as follows:
+	// Javascript files already running in the page are only replaced, removed, or run
+	// again if they accept hot replacement by calling gooey.hot.accept, which allows
+	// the file to restore the state saved by the handlers of the old version given to
+	// gooey.hot.dispose.  Otherwise the whole page is reloaded.  See gooey.js for more.
+	//
+	// If a watched file can't be read, or a reloaded script throws an error as it runs,
+	// then the errors are shown in a dismissible overlay at the top of the page along
+	// with the file and, for scripts, the line of each error.  The overlay is removed
+	// on the next reload that has no errors.  When the app is run by the gooey dev
+	// command, the errors of a failed Go build are shown in the same overlay until a
+	// build succeeds, even if ReloadWatchDir is the empty string.
+	//
+	// The tags are ordered by the following rule, which applies to CSS and Javascript
+	// files alike.  If a file has a number after a dot in the file name but before the
+	// .css or .js extension then that number declares the ordering amongst other
+	// numbered files, which will also be inserted before the non numbered files.  The
+	// non numbered files, and numbered files sharing a number, are ordered by their
+	// path.  For example, suppose we have three Javascript files: baz.js, bar.3.js,
+	// and foo.0.js, and suppose the current <head> tag is as follows:
 	//
 	// <head>
 	//   <script src="/somescript.js"></script>
@@ -130,27 +328,143 @@ type Server struct {
 	// <head>
 	//   <script src="/somescript.js"></script>
 	//   <link rel="stylesheet" href="/somestyle.css">
-	//   <script id="gooey-reload-js-content">
-	//   /* concatenated content of foo.0.js, bar.3.js, and then baz.js */
-	//   </script>
+	//   <script data-gooey-file="foo.0.js">/* content of foo.0.js */</script>
+	//   <script data-gooey-file="bar.3.js">/* content of bar.3.js */</script>
+	//   <script data-gooey-file="baz.js">/* content of baz.js */</script>
 	// </head>
 	ReloadWatchDir string
 
-	// If ReloadWatchDir is not the empty then  gooey will ignore all files
-	// that match any of these patterns.  That match algorithm used is the
-	// same as specified in path.Match.
+	// If non nil then the index page and the body.html of ReloadWatchDir, along with
+	// any other file reloaded as the body through ReloadRules, are executed as
+	// html/template templates with the value returned by TemplateData.  The index page
+	// is rendered each time it is requested and the body is rendered each time it
+	// changes and each time a client connects.  This allows pages in development to
+	// show the same Go data that the templates will be rendered with in production.
+	// An error in parsing or executing a body template is shown in the reload error
+	// overlay, see ReloadWatchDir, while an error in the index template is returned
+	// in place of the page.
+	TemplateData func() interface{}
+
+	// Maps the files of ReloadWatchDir to what clients do when one of them changes,
+	// which allows files other than body.html, CSS, and Javascript to be hot reloaded.
+	// When a file changes the first rule whose pattern matches the file is used.  These
+	// rules are checked before the default rules, which treat body.html, *.css, and
+	// *.js files as described for ReloadWatchDir, and a file that matches no rule is
+	// ignored.  For example, the following rules reload the whole page when the
+	// index page or an image changes and tell the client when a template changes:
+	//
+	//     []gooey.ReloadRule{
+	//         {Pattern: "index.html", Action: gooey.ReloadPage},
+	//         {Pattern: "images/*", Action: gooey.ReloadPage},
+	//         {Pattern: "*.tmpl", Action: gooey.ReloadEvent, Event: "template"},
+	//     }
+	//
+	// The ReloadEvent action calls gooey.OnReload in the client with an object that
+	// holds the rule's Event, the file's Path relative to ReloadWatchDir, and whether
+	// the file was Removed.
+	ReloadRules []ReloadRule
+
+	// If ReloadWatchDir is not the empty then gooey will ignore all files that match
+	// these patterns.  The patterns follow the rules of a .gitignore file: a pattern
+	// without a slash matches a file or directory name at any depth, a pattern with a
+	// leading or middle slash is relative to ReloadWatchDir, a trailing slash only
+	// matches directories, ** matches any number of directories, and a leading ! makes
+	// a previously ignored path not ignored again.  Everything within an ignored
+	// directory is ignored and ignored directories are not watched at all, so ignoring
+	// large directories such as node_modules/ keeps watching cheap.
+	//
+	// If ReloadWatchDir contains a .gooeyignore file then its patterns, one per line,
+	// are read when the server starts and apply after these patterns.
 	ReloadIgnorePatterns []string
 
+	// On Linux, ReloadWatchDir is watched for changes with inotify.  Changes are sent
+	// to clients once no further change has occurred for the ReloadDebounce duration,
+	// which gathers the burst of writes an editor may make when saving a file into one
+	// reload.  If ReloadDebounce is zero or less then 100 milliseconds is used.
+	//
+	// On other platforms, or if ReloadPolling is true or inotify is unavailable, then
+	// ReloadWatchDir is instead scanned for changes on every ReloadInterval.  If
+	// ReloadInterval is zero or less then the directory is scanned once a second.
+	ReloadDebounce time.Duration
+	ReloadInterval time.Duration
+	ReloadPolling  bool
+
+	// If non empty and Addr doesn't give a port, then the port the server listens on
+	// is remembered between runs in a per-user config file named after RememberPort,
+	// such as ~/.config/gooey/RememberPort.port on Linux.  On the next run the server
+	// tries to listen on the remembered port first and falls back to a port picked
+	// by the OS if it's taken.  Keeping the same port keeps the same origin, so the
+	// browser's localStorage, cookies, and bookmarks for the app survive a restart.
+	RememberPort string
+
+	// If non empty then the server runs in single instance mode with SingleInstance
+	// as the name of the app.  Only one instance of the app, per user, may run at a
+	// time.  When a second instance is started its Start method forwards the program's
+	// command line arguments to the running instance and returns ErrAlreadyRunning
+	// without starting a server.  The running instance then opens a new tab with its
+	// Launcher, as gooey.OpenNewTab does, unless NoAutoOpen is set, and passes the
+	// arguments to the App if it implements ArgsApp.  The instance holds a lock file
+	// in the user's runtime directory ($XDG_RUNTIME_DIR or the user's cache
+	// directory) that names a control socket on the loopback interface along with a
+	// secret that other instances must present.
+	SingleInstance string
+
 	// If this field is set to true then the server will not automatically shutdown after
 	// the last client connection to the server is closed.
 	NoAutoShutdown bool
 
-	// If this field is set to true then the server will not open a browser tab in the
-	// users default browser on server start.  It should be noted that if this field
+	// When the last client connection closes, the server waits ShutdownGracePeriod
+	// for a client to connect again, as happens when the page is refreshed, before
+	// shutting down.  If ShutdownGracePeriod is zero or less then 500 milliseconds is
+	// used, which may be too short for heavy pages that are slow to reload.
+	ShutdownGracePeriod time.Duration
+
+	// If greater than zero then the server shuts down once no client has connected or
+	// sent a message for IdleTimeout, even if tabs are still open.  Messages sent to
+	// the clients don't count as activity.  The idle timeout applies even when
+	// NoAutoShutdown is set.
+	IdleTimeout time.Duration
+
+	// If non nil then OnShutdownRequested is called before the server shuts down
+	// because the last client left or the IdleTimeout passed, allowing the App to
+	// delay or veto the shutdown, such as while a long running job finishes.  It
+	// returns zero to let the server shut down, a positive duration to be asked again
+	// after that long (unless a client connects again in the meantime, or for
+	// ShutdownIdle, a client becomes active), or a negative duration to veto the
+	// shutdown.  After a veto the server keeps running until the last client leaves
+	// again or, for ShutdownIdle, another IdleTimeout passes.  Closing the done
+	// channel given to Start always shuts the server down without asking.
+	OnShutdownRequested func(reason ShutdownReason) time.Duration
+
+	// Opens the server's URL when the server starts and when a client calls
+	// gooey.OpenNewTab.  If Launcher is nil then DefaultBrowser is used, which opens
+	// a tab in the user's default browser or the browser named by $BROWSER.  Use
+	// AppWindow for a window without browser chrome or BrowserCommand to run a
+	// specific browser.  Launch failures are reported through ErrorLog and ErrorC.
+	Launcher Launcher
+
+	// If this field is set to true then the server will not open a browser tab with
+	// its Launcher on server start.  It should be noted that if this field
 	// is set to true then it might be wise to assign a custom address to the Addr
 	// field so one knows how to connect to the server.
 	NoAutoOpen bool
 
+	// A channel, if non nil, that is sent the server's URL once the server is
+	// listening.  This is the only way to learn the port picked by the OS when Addr
+	// doesn't give one, such as for the default of "127.0.0.1:".  If the server's
+	// Authenticator is a TokenAuth then the URL carries the token so that opening
+	// the URL authenticates the browser.  The same URL is printed by PrintURL and
+	// PrintQRCode, while the Launcher is instead given a redirect page so that
+	// the token isn't visible in the browser's command line.
+	ReadyC chan<- string
+
+	// If PrintURL is true then the server's URL is printed to stdout once the server
+	// is listening, and if PrintQRCode is true then the URL is also printed as a QR
+	// code drawn with block characters.  Along with NoAutoOpen, these allow the app to
+	// be used where no browser can be opened, such as over SSH or in a container.
+	PrintURL    bool
+	PrintQRCode bool
+
 	// A logger for the server to post informational to, essentially enabling a verbose
 	// mode.  If set to nil then no info messages will be posted.
 	InfoLog *log.Logger
@@ -167,6 +481,38 @@ type Server struct {
 	// ErrorLog.  Note that these two fields are not mutually exclusive as any error
 	// encountered will be written to the error log and this channel.
 	ErrorC chan<- error
+
+	// The number of currently open websocket connections.
+	active int32
+
+	// The time.Time of the last client activity.
+	activity atomic.Value
+
+	// Watches ReloadWatchDir for all connections, nil if there is nothing to watch.
+	reload *reloader
+
+	// Whether the server has been started by the gooey dev command.
+	dev bool
+
+	// Whether the server's Launcher can open the server's URL.
+	launchable bool
+
+	// The server's URL once the server is listening.
+	url string
+
+	// The path of the index page.
+	index string
+
+	// The temporary directory holding the default index page, the favicon, and
+	// the redirect pages opened by the Launcher.
+	dir string
+
+	// The open connections, by which SendTo finds the clients of a window.
+	mu      sync.Mutex
+	clients map[*client]struct{}
+
+	// The errors of the gooey dev command's last build, nil if it succeeded.
+	buildErrs []reloadError
 }
 
 // Start the server and allow incoming client connections. If an intialization error
@@ -174,28 +520,74 @@ type Server struct {
 // started and this call blocks until there are no more clients connected (if
 // NoAutoShutdown is false) or the done channel is closed.
 func (server *Server) Start(done <-chan struct{}, app App) error {
-	addr := "127.0.0.1:"
-	if server.Addr != "" {
-		addr = server.Addr
+	// When run by the gooey dev command the server must listen on the address the
+	// command gives it and stay up between browser refreshes since the command
+	// opens the browser itself and restarts the server on every rebuild.
+	server.dev = os.Getenv(DevAddrEnv) != ""
+	if server.dev {
+		server.Addr = os.Getenv(DevAddrEnv)
+		server.NoAutoOpen = true
+		server.NoAutoShutdown = true
 	}
 
-	listener, err := net.Listen("tcp", addr)
-	if err != nil {
-		return fmt.Errorf("Failed to create net.listener -- %s", err)
+	if server.Listener != nil {
+		defer server.Listener.Close()
+	}
+
+	var lock *instanceLock
+	if server.SingleInstance != "" {
+		l, err := acquireInstance(server.SingleInstance, os.Args[1:])
+		if err != nil {
+			return err
+		}
+		lock = l
+		defer lock.release()
+	}
+
+	listener := server.Listener
+	if listener == nil || server.dev {
+		network, addr := server.Network, "127.0.0.1:"
+		if network == "" || server.dev {
+			network = "tcp"
+		}
+		if server.Addr != "" {
+			addr = server.Addr
+		} else if strings.HasPrefix(network, "unix") {
+			return fmt.Errorf("No socket path given in Addr for the %s network", network)
+		}
+		l, err := server.listen(network, addr)
+		if err != nil {
+			return fmt.Errorf("Failed to create net.listener -- %s", err)
+		}
+		listener = l
+		defer listener.Close()
+	}
+
+	// A browser can only be opened onto a TCP address.
+	_, server.launchable = listener.Addr().(*net.TCPAddr)
+
+	scheme := "http"
+	if config, err := server.tlsConfig(listener.Addr()); err != nil {
+		return err
+	} else if config != nil {
+		scheme = "https"
+		listener = tls.NewListener(listener, config)
 	}
-	defer listener.Close()
 
 	dir, err := ioutil.TempDir("", "gooey_server")
 	if err != nil {
 		return fmt.Errorf("Failed to create a temporary gooey_server directory -- %s\n", err)
 	}
 	defer os.RemoveAll(dir)
+	server.dir = dir
 
-	redirect, err := buildTempFile(dir, "redirect.html", REDIRECT, listener)
-	if err != nil {
-		return err
+	url := server.serverURL(scheme, listener.Addr())
+	server.url = url
+
+	server.index = filepath.Join(dir, "index.html")
+	if server.WebServeDir != "" && !server.ForceIndexAndFavIcon {
+		server.index = filepath.Join(server.WebServeDir, "index.html")
 	}
-	defer redirect.close()
 
 	index, err := createTempFile(dir, "index.html")
 	if err != nil {
@@ -230,9 +622,8 @@ func (server *Server) Start(done <-chan struct{}, app App) error {
 		}
 	}
 
-	http.HandleFunc("/gooeynewtab", func(w http.ResponseWriter, r *http.Request) {
-		exec.Command(BROWSE, redirect.name()).Start()
-	})
+	http.HandleFunc("/gooeynewtab", server.handleNewTab)
+	handleClient(http.DefaultServeMux)
 
 	if server.ForceIndexAndFavIcon {
 		http.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
@@ -258,146 +649,305 @@ func (server *Server) Start(done <-chan struct{}, app App) error {
 	}
 
 	var (
-		onOpen   = make(chan *websocket.Conn)
+		onOpen   = make(chan *client)
 		shutdown = make(chan struct{})
+		handler  = http.Handler(http.DefaultServeMux)
 	)
 
+	server.reload = nil
+	if server.ReloadWatchDir != "" {
+		if r, err := server.watchReloadDir(done); err != nil {
+			server.errorln("Could not watch web files --", err)
+		} else {
+			server.reload = r
+		}
+	}
+
+	if buildLog := os.Getenv(DevBuildLogEnv); server.dev && buildLog != "" {
+		go server.watchBuildLog(done, buildLog)
+	}
+
 	go server.monitorClients(done, onOpen, shutdown, app)
 	http.HandleFunc("/gooeywebsocket", server.handleWebsocket(onOpen))
 	if !server.NoAutoOpen {
-		exec.Command(BROWSE, redirect.name()).Start()
+		server.launch(url)
+	}
+	if server.SecurityHeaders != nil || server.TemplateData != nil {
+		handler = server.serveIndex(handler)
+	}
+	if len(server.Routes) > 0 {
+		handler = server.serveRoutes(handler)
+	}
+	if server.Authenticator != nil {
+		handler = server.authenticate(handler)
+	}
+	if server.SecurityHeaders != nil {
+		handler = server.secure(handler)
 	}
-	go http.Serve(listener, nil)
+	if lc, ok := app.(Lifecycle); ok {
+		lc.ServerReady(url)
+	}
+	go http.Serve(listener, handler)
+	if lock != nil {
+		lock.serve(func(args []string) {
+			server.infoln("Another instance was launched with arguments", args)
+			if !server.NoAutoOpen {
+				server.launch(url)
+			}
+			if aa, ok := app.(ArgsApp); ok {
+				go aa.ReceiveArgs(args)
+			}
+		})
+	}
+	server.ready(url)
 
 	<-shutdown
 
 	return nil
 }
 
-func (s *Server) handleWebsocket(onOpen chan<- *websocket.Conn) func(http.ResponseWriter, *http.Request) {
+// DevAddrEnv is the environment variable that the gooey dev command sets for the
+// programs it runs.  If it is set then Server.Start listens on its address in place
+// of Addr, doesn't open a browser tab, doesn't shut down when the last client closes,
+// and tells clients to reconnect and refresh the page after the server restarts.
+const DevAddrEnv = "GOOEY_DEV_ADDR"
+
+// A websocket connection along with the session it belongs to.
+type client struct {
+	conn    *websocket.Conn
+	session *Session
+
+	// Messages sent to the client by Server.SendTo.
+	direct chan interface{}
+
+	// Closed once the connection has closed.
+	gone chan struct{}
+}
+
+func (s *Server) handleWebsocket(onOpen chan<- *client) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ws := websocket.Upgrader{
 			ReadBufferSize:  4096,
 			WriteBufferSize: 4096,
-			CheckOrigin:     func(r *http.Request) bool { return true },
+			CheckOrigin:     s.checkOrigin,
 		}
 		c, err := ws.Upgrade(w, r, nil)
 		if err != nil {
 			s.errorln("Failed to upgrade websocket connection -- ", err)
+		} else if n := atomic.AddInt32(&s.active, 1); s.MaxConnections > 0 && int(n) > s.MaxConnections {
+			atomic.AddInt32(&s.active, -1)
+			s.errorln("Rejected connection from", r.RemoteAddr, "-- maximum of", s.MaxConnections, "connections reached")
+			msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections")
+			c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			c.Close()
 		} else {
-			onOpen <- c
+			session := &Session{
+				Identity:   requestIdentity(r),
+				RemoteAddr: r.RemoteAddr,
+			}
+			pageSession(session, r)
+			onOpen <- &client{
+				conn:    c,
+				session: session,
+				direct:  make(chan interface{}),
+				gone:    make(chan struct{}),
+			}
 		}
 	}
 }
 
-func (server *Server) monitorClients(done <-chan struct{}, onOpen <-chan *websocket.Conn, shutdown chan<- struct{}, app App) {
+func (server *Server) monitorClients(done <-chan struct{}, onOpen <-chan *client, shutdown chan<- struct{}, app App) {
 	var (
 		connections = 0
+		present     = false // Whether a client has connected since the last one left.
+		stopping    = false
+		quit        = make(chan struct{})
 		onClose     = make(chan struct{})
-		noMoreConns = make(chan struct{})
-		noMoreTimer *time.Timer
+		graceTimer  *time.Timer
+		grace       <-chan time.Time
+		idleTimer   *time.Timer
+		idle        <-chan time.Time
 	)
 
-	if server.NoAutoShutdown {
-		for {
-			select {
-			case <-done:
-				close(shutdown)
-				return
-			case conn := <-onOpen:
-				go server.connect(conn, done, onClose, app)
+	lifecycle, _ := app.(Lifecycle)
+
+	stop := func() {
+		if !stopping {
+			server.infoln("Shutting down gooey web server")
+			if lifecycle != nil {
+				lifecycle.ShuttingDown()
 			}
+			stopping = true
+			close(quit)
+			close(shutdown)
 		}
 	}
 
-	for {
+	gracePeriod := server.ShutdownGracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = 500 * time.Millisecond
+	}
+
+	server.touch()
+	if server.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(server.IdleTimeout)
+		idle = idleTimer.C
+		defer idleTimer.Stop()
+	}
+
+	// Once shutting down, the open connections are closed by quit and they are
+	// counted as they close so that none of them blocks on the onClose channel.
+	for !stopping || connections > 0 {
 		select {
-		case conn := <-onOpen:
-			open := func() {
-				connections++
-				server.infoln("Connection opened -- count", connections)
-				go server.connect(conn, done, onClose, app)
-			}
-			// In case the user is spamming the refresh button on the browser we want
-			// stop the timer as the connection is being reopened.  This stops a case
-			// where a timer from an earlier refresh is fired at the right time during
-			// another refresh and connections == 0 which will then inadvertently
-			// shut down the server.
-			if noMoreTimer == nil {
-				open()
-			} else {
-				if noMoreTimer.Stop() {
-					noMoreTimer = nil
-					open()
+		case <-done:
+			stop()
+			done = nil
+
+		case c := <-onOpen:
+			connections++
+			server.infoln("Connection opened -- count", connections)
+			server.touch()
+			if !present {
+				present = true
+				if lifecycle != nil {
+					lifecycle.FirstClientConnected(c.session)
 				}
 			}
+			go server.connect(c, quit, onClose, app)
+
+			// The user may have refreshed the page, which closes the connection and
+			// immediately opens a new one, so a pending shutdown must be called off.
+			if graceTimer != nil {
+				graceTimer.Stop()
+				graceTimer, grace = nil, nil
+			}
 
 		case <-onClose:
 			connections--
 			server.infoln("Connection closed -- count", connections)
-			// We want to give some time before shutting down altogether to
-			// handle the case where there is only one connection and the
-			// page is being refreshed, which causes the connection to close
-			// and reopen immediately.
-			if noMoreTimer == nil {
-				noMoreTimer = time.AfterFunc(500*time.Millisecond, func() { noMoreConns <- struct{}{} })
-			}
-
-		case <-noMoreConns:
 			if connections < 0 {
 				// If we get here then the synchronization is broken.
 				panic("Number of connections dropped below zero")
-			} else if connections == 0 {
-				server.infoln("Shutting down gooey web server")
-				close(shutdown)
-				return
+			}
+			// Give the last client some time to come back before shutting down to
+			// handle the page being refreshed.
+			if connections == 0 && !stopping {
+				graceTimer = time.NewTimer(gracePeriod)
+				grace = graceTimer.C
+			}
+
+		case <-grace:
+			graceTimer, grace = nil, nil
+			if present {
+				present = false
+				if lifecycle != nil {
+					lifecycle.LastClientLeft()
+				}
+			}
+			if server.NoAutoShutdown {
+				continue
+			}
+			if d := server.requestShutdown(ShutdownLastClient); d == 0 {
+				stop()
+			} else if d > 0 {
+				graceTimer = time.NewTimer(d)
+				grace = graceTimer.C
+			}
+
+		case <-idle:
+			since := time.Since(server.activity.Load().(time.Time))
+			if since < server.IdleTimeout {
+				idleTimer.Reset(server.IdleTimeout - since)
+			} else if d := server.requestShutdown(ShutdownIdle); d == 0 {
+				stop()
+			} else if d > 0 {
+				idleTimer.Reset(d)
 			} else {
-				// Suppose the user has two tabs open, closes one, and eventually the
-				// noMoreTimer fires.  In this case, we reach this point here and must
-				// set the timer to nil in case the user opens another tab.  If not, then
-				// noMoreTimer.Stop() returns false and the connection doesn't open.
-				noMoreTimer = nil
+				server.touch()
+				idleTimer.Reset(server.IdleTimeout)
 			}
 		}
 	}
 }
 
-func (server *Server) connect(conn *websocket.Conn, done <-chan struct{}, onClose chan<- struct{}, app App) {
+// ShutdownReason is the reason a Server wishes to shut down, as given to the
+// Server's OnShutdownRequested hook.
+type ShutdownReason int
+
+const (
+	// The last client connection closed and no client connected again within the
+	// ShutdownGracePeriod.
+	ShutdownLastClient ShutdownReason = iota
+
+	// No message has been received from any client for the IdleTimeout.
+	ShutdownIdle
+)
+
+func (r ShutdownReason) String() string {
+	switch r {
+	case ShutdownLastClient:
+		return "last client closed"
+	case ShutdownIdle:
+		return "idle timeout"
+	}
+	return fmt.Sprintf("ShutdownReason(%d)", int(r))
+}
+
+// Asks the OnShutdownRequested hook whether the server may shut down, returning
+// zero if it may, the duration to wait before asking again, or a negative duration
+// if the shutdown was vetoed.
+func (s *Server) requestShutdown(reason ShutdownReason) time.Duration {
+	if s.OnShutdownRequested == nil {
+		return 0
+	}
+	d := s.OnShutdownRequested(reason)
+	if d > 0 {
+		s.infoln("Shutdown for", reason, "delayed by", d)
+	} else if d < 0 {
+		s.infoln("Shutdown for", reason, "vetoed")
+	}
+	return d
+}
+
+// Records client activity for the IdleTimeout.
+func (s *Server) touch() {
+	s.activity.Store(time.Now())
+}
+
+func (server *Server) connect(c *client, done <-chan struct{}, onClose chan<- struct{}, app App) {
 	var (
+		conn     = c.conn
+		session  = c.session
 		stop     = make(chan struct{})
-		reload   = make(chan interface{})
-		incoming = make(chan []byte)
-		outgoing = make(chan interface{})
+		notices  = make(chan gooeyMessage)
+		navigate = make(chan string)
+		limiter  = newRateLimiter(server.MessageRate, server.MessageBurst)
+		current  atomic.Value
 	)
+	defer atomic.AddInt32(&server.active, -1)
+	defer close(c.gone)
 
-	go app.Start(stop, incoming, outgoing)
+	server.addClient(c)
+	defer server.removeClient(c)
 
-	// This is pretty inefficient to watch the reload directory for each websocket
-	// connection but we'll allow it as it is intended for development where it is
-	// expected that only one or two tabs may be open at a time.
-	if server.ReloadWatchDir != "" {
-		interval := 1 * time.Second
-		updates, err := filewatch.Watch(done, server.ReloadWatchDir, true, &interval)
+	if server.MaxMessageSize > 0 {
+		conn.SetReadLimit(server.MaxMessageSize)
+	}
 
-		if err != nil {
-			server.errorln("Could not watch web files --", err)
-		} else {
-			go func() {
-				var (
-					buf bytes.Buffer
-					js  = make(map[string]string)
-					css = make(map[string]string)
-				)
-				for {
-					select {
-					case <-done:
-						return
-					case us := <-updates:
-						reload <- server.reloadWebContent(us, css, js, buf)
-					}
-				}
-			}()
-		}
+	// The App is restarted, with new channels, when the client navigates to the
+	// page of another route.
+	run := server.runApp(app, session)
+	current.Store(run)
+	defer func() { close(run.closed) }()
+
+	var (
+		sub    *reloadSubscriber
+		reload <-chan struct{}
+	)
+	if server.reload != nil {
+		sub = server.reload.subscribe(server.showsIndex(session.Path))
+		reload = sub.notify
+		defer server.reload.unsubscribe(sub)
 	}
 
 	go (func() {
@@ -408,20 +958,49 @@ func (server *Server) connect(conn *websocket.Conn, done <-chan struct{}, onClos
 					server.infoln("Client closing connection")
 					close(stop)
 					return
+				} else if err == websocket.ErrReadLimit {
+					// The websocket package has already sent the close message.
+					server.errorln("Closing connection from", session.RemoteAddr, "-- message exceeds", server.MaxMessageSize, "bytes")
+					close(stop)
+					return
 				} else {
-					ok := true
+					// A failed read can't be retried so the connection is closed
+					// unless it is already being closed.
 					select {
-					case _, ok = <-stop:
-					case _, ok = <-done:
+					case <-stop:
+					case <-done:
 					default:
 						server.errorln("ReadMessage error --", err)
+						close(stop)
 					}
-					if !ok {
-						return
-					}
+					return
+				}
+			} else if !limiter.allow() {
+				server.errorln("Closing connection from", session.RemoteAddr, "-- message rate limit exceeded")
+				msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "message rate limit exceeded")
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+				close(stop)
+				return
+			} else if page, ok := navigateTo(msg); ok {
+				select {
+				case navigate <- page:
+				case <-done:
+					return
+				}
+			} else if e := server.authorize(session, msg); e != nil {
+				select {
+				case notices <- gooeyMessage{"gooey-server-error", e}:
+				case <-done:
+					return
 				}
 			} else {
-				incoming <- msg
+				server.touch()
+				// A message sent as the App is restarted is dropped.
+				run := current.Load().(*appRun)
+				select {
+				case run.incoming <- msg:
+				case <-run.closed:
+				}
 			}
 		}
 	})()
@@ -434,6 +1013,13 @@ func (server *Server) connect(conn *websocket.Conn, done <-chan struct{}, onClos
 		}
 	}
 
+	if server.dev {
+		send(gooeyMessage{"gooey-server-dev", nil})
+		if errs := server.buildErrors(); len(errs) > 0 {
+			send(gooeyMessage{"gooey-server-build-errors", errs})
+		}
+	}
+
 	for {
 		select {
 		case <-stop:
@@ -443,7 +1029,11 @@ func (server *Server) connect(conn *websocket.Conn, done <-chan struct{}, onClos
 			return
 
 		case <-done:
-			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			code := websocket.CloseNormalClosure
+			if server.dev {
+				code = websocket.CloseServiceRestart
+			}
+			msg := websocket.FormatCloseMessage(code, "")
 			if err := conn.WriteMessage(websocket.CloseMessage, msg); err != nil {
 				server.errorln("WriteMessage error --", err)
 			} else {
@@ -453,139 +1043,51 @@ func (server *Server) connect(conn *websocket.Conn, done <-chan struct{}, onClos
 			onClose <- struct{}{}
 			return
 
-		case content := <-outgoing:
+		case content := <-run.outgoing:
 			send(content)
 
-		case content := <-reload:
-			server.infoln("Reloading web content")
-			send(struct {
-				GooeyMessage string
-				GooeyContent interface{}
-			}{
-				GooeyMessage: "gooey-server-reload-content",
-				GooeyContent: content,
-			})
-		}
-	}
-}
-
-type contentUpdate struct {
-	Body, Javascript, CSS string
-}
-
-func (s *Server) reloadWebContent(updates []filewatch.Update, css, js map[string]string, buf bytes.Buffer) contentUpdate {
-	var update contentUpdate
-
-	check := func(u filewatch.Update, m map[string]string) (hasUpdate bool) {
-		if u.WasRemoved {
-			hasUpdate = true
-			delete(m, u.AbsPath)
-		} else {
-			if content, err := ioutil.ReadFile(u.AbsPath); err != nil {
-				// This can occur for files that are created by other programs,
-				// such as a text editor, which may create backup files and
-				// delete them before the next filewatch update is received.
-				if os.IsNotExist(err) {
-					hasUpdate = true
-					delete(m, u.AbsPath)
-				} else {
-					s.errorln("Failed to read", u.AbsPath, "--", err)
+		case page := <-navigate:
+			server.touch()
+			nav := navigation{Page: page}
+			s := server.navigateSession(c, page)
+			if pattern, _ := server.matchRoute(s.Path); pattern != run.pattern {
+				old := run
+				close(old.closed)
+				run = server.runApp(app, s)
+				current.Store(run)
+				if run.route.Page != old.route.Page {
+					nav.Body = server.routeBody(run.route)
 				}
-			} else {
-				hasUpdate = true
-				m[u.AbsPath] = string(content)
-			}
-		}
-		return
-	}
-
-	var jsUpdate, cssUpdate bool
-
-	for _, u := range updates {
-		ignore := false
-		for _, pattern := range s.ReloadIgnorePatterns {
-			match, err := path.Match(pattern, filepath.Base(u.AbsPath))
-			if err != nil {
-				s.errorln("Failed to match pattern", pattern, "for path", u.AbsPath, "--", err)
-			} else if match {
-				ignore = true
-				break
 			}
-		}
-		if ignore {
-			continue
-		}
-
-		if strings.HasSuffix(u.AbsPath, "body.html") {
-			if u.WasRemoved {
-				update.Body = "<div></div>"
-			} else {
-				if body, err := ioutil.ReadFile(u.AbsPath); err != nil {
-					s.errorln("Failed to read", u.AbsPath, "--", err)
-				} else {
-					update.Body = string(body)
+			if sub != nil {
+				index := server.showsIndex(s.Path)
+				sub.setIndex(index)
+				if index && nav.Body != "" {
+					if body := server.reload.indexBody(); body != "" {
+						nav.Body = body
+					}
 				}
 			}
-		}
-		if strings.HasSuffix(u.AbsPath, ".js") {
-			jsUpdate = check(u, js)
-		}
-		if strings.HasSuffix(u.AbsPath, ".css") {
-			cssUpdate = check(u, css)
-		}
-	}
-
-	if cssUpdate {
-		buf.Reset()
-		for _, c := range css {
-			buf.WriteString(c)
-		}
-		update.CSS = buf.String()
-	}
-
-	// combine and sort
-	if jsUpdate {
-		buf.Reset()
+			send(gooeyMessage{"gooey-server-navigate", nav})
 
-		numbered := make(map[int]string, len(js))
-		nonsorted := make([]string, 0, len(js))
-
-		for path, _ := range js {
-			sp := strings.Split(filepath.Base(path), ".")
-			ln := len(sp)
-			if ln >= 3 {
-				n := sp[ln-2]
-				if len(n) > 1 {
-					n = strings.TrimLeft(n, "0")
-				}
-				if i, err := strconv.ParseInt(n, 10, 32); err == nil {
-					numbered[int(i)] = path
-				} else {
-					nonsorted = append(nonsorted, path)
-				}
-			} else {
-				nonsorted = append(nonsorted, path)
-			}
-		}
+		case content := <-c.direct:
+			send(content)
 
-		idx, sorted := 0, make([]int, len(numbered))
-		for i, _ := range numbered {
-			sorted[idx] = i
-			idx++
-		}
-		sort.Ints(sorted)
+		case <-reload:
+			server.infoln("Reloading web content")
+			send(gooeyMessage{"gooey-server-reload-content", sub.take()})
 
-		for _, n := range sorted {
-			path := numbered[n]
-			buf.WriteString(js[path])
-		}
-		for _, path := range nonsorted {
-			buf.WriteString(js[path])
+		case notice := <-notices:
+			send(notice)
 		}
-		update.Javascript = buf.String()
 	}
+}
 
-	return update
+// A message from the server to gooey.js that is handled by gooey.js itself rather
+// than being passed to gooey.OnMessage.
+type gooeyMessage struct {
+	GooeyMessage string
+	GooeyContent interface{}
 }
 
 func (s *Server) infoln(args ...interface{}) {
@@ -613,14 +1115,6 @@ func createTempFile(dir, name string) (*tempFile, error) {
 	return (*tempFile)(file), nil
 }
 
-func buildTempFile(dir, name, tpl string, data interface{}) (*tempFile, error) {
-	file, err := createTempFile(dir, name)
-	if err != nil {
-		return nil, err
-	}
-	return file, file.buildTemplate(tpl, data)
-}
-
 type tempFile os.File
 
 func (f *tempFile) close() {
@@ -644,17 +1138,3 @@ func (f *tempFile) Write(bs []byte) (n int, err error) {
 	file := (*os.File)(f)
 	return file.Write(bs)
 }
-
-func (file *tempFile) buildTemplate(tpl string, data interface{}) error {
-	name := file.name()
-	temp, err := template.New(name).Parse(tpl)
-
-	if err != nil {
-		return fmt.Errorf("Failed to parse template, %s -- %s\n", name, err)
-	}
-	if err := temp.Execute(file, data); err != nil {
-		return fmt.Errorf("Failed to execute template, %s -- %s\n", name, err)
-	}
-	file.close()
-	return nil
-}
@@ -4,10 +4,27 @@
     const CLOSING    = 2;
     const CLOSED     = 3;
 
-    let location = window.location.origin.substring(7);
-    let socket   = new WebSocket('ws://' + location + '/gooeywebsocket');
+    // A page opened in a named window carries the window's name in its URL, which
+    // is kept as the name of the browser window so it outlives the page.  The
+    // server is told the window's name along with the page's URL.
+    let params = new URLSearchParams(window.location.search);
+    if (params.has('gooeywindow')) {
+        window.name = params.get('gooeywindow');
+    }
+
+    let scheme   = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+    let socket   = new WebSocket(scheme + window.location.host + '/gooeywebsocket' +
+                                 '?window=' + encodeURIComponent(window.name) +
+                                 '&page=' + encodeURIComponent(window.location.pathname + window.location.search));
     let gooey    = undefined;
 
+    // Dynamically created <script> and <style> elements must carry the nonce
+    // of the page to be allowed under a Content-Security-Policy.  There is no
+    // current script when run as a module so the nonce is taken from any script
+    // of the page instead.
+    let nonceScript = document.currentScript || document.querySelector('script[nonce]');
+    let nonce       = nonceScript ? nonceScript.nonce : '';
+
     // Refer to gooey instead of window.gooey for better minification.
     if (window.hasOwnProperty("gooey")) {
         gooey = window.gooey;
@@ -16,6 +33,15 @@
         window.gooey = gooey;
 
         gooey.OnMessage = function(msg) { console.log(msg); };
+        gooey.OnReload = function(evt) {
+            console.log('[GOOEY] Reload event ' + evt.Event + ' for ' + evt.Path);
+        };
+        gooey.OnNavigate = function(page) {
+            console.log('[GOOEY] Navigated to ' + page);
+        };
+        gooey.OnError = function(err) {
+            console.error('[GOOEY] ' + err.Code + ': ' + err.Message);
+        };
         gooey.Send = function(payload) {
             if (socket.readyState === OPEN) {
                 socket.send(JSON.stringify(payload));
@@ -32,11 +58,107 @@
         };
         gooey.OpenNewTab = function() {
             let req = new XMLHttpRequest();
-            req.open('GET', window.location + 'gooeynewtab', true);
+            req.open('GET', window.location.origin + '/gooeynewtab', true);
             req.send();
         };
+        // Changes the page's URL to path, which may include a query, without
+        // loading a new page or closing the connection to the server.  The
+        // server starts the App of the path's route, replacing the body of the
+        // page if the route's page differs, and then gooey.OnNavigate is called.
+        gooey.Navigate = function(path) {
+            window.history.pushState(null, '', path);
+            navigate();
+        };
+        // Opens the page at path, with the query parameters in the params
+        // object, in the browser window called name.  If a window of that name
+        // is open then the page replaces its current one.  If the browser blocks
+        // the window from opening then the server opens it instead.
+        gooey.OpenWindow = function(name, path, params) {
+            let query = new URLSearchParams(params || {});
+            let url   = new URL(path, window.location.origin);
+            query.forEach(function(value, key) {
+                url.searchParams.append(key, value);
+            });
+            url.searchParams.set('gooeywindow', name);
+
+            if (window.open(url.toString(), name) === null) {
+                let req = new XMLHttpRequest();
+                req.open('GET', window.location.origin + '/gooeynewtab' +
+                         '?window=' + encodeURIComponent(name) +
+                         '&path=' + encodeURIComponent(path) +
+                         '&query=' + encodeURIComponent(query.toString()), true);
+                req.send();
+            }
+        };
+
+        // Hot module replacement for the Javascript files hot reloaded from the
+        // server's ReloadWatchDir.  Each file is a module and when a module
+        // changes the page is reloaded unless the module has called accept,
+        // in which case the module's dispose handlers are called and then the
+        // new version of the module is run in place of the old one.  Both
+        // functions must be called while the module's top level code runs.
+        gooey.hot = {
+            // Declares that the running module can be replaced.  When the
+            // module is run as the replacement of an earlier version then fn
+            // is called with the data object filled by the dispose handlers.
+            accept: function(fn) {
+                let mod = currentModule('accept');
+                if (mod) {
+                    mod.accepted = true;
+                    if (fn && mod.data !== undefined) {
+                        fn(mod.data);
+                    }
+                }
+            },
+            // Registers fn to be called with a data object just before the
+            // running module is replaced or removed so that it may clean up
+            // and save any state the new version should restore.
+            dispose: function(fn) {
+                let mod = currentModule('dispose');
+                if (mod) {
+                    mod.disposers.push(fn);
+                }
+            }
+        };
+    }
+
+    // Hot module state keyed by the path of the module's file.
+    let modules = {};
+
+    function currentModule(caller) {
+        let script = document.currentScript;
+        let path   = script ? script.getAttribute('data-gooey-file') : null;
+        if (path === null || !modules.hasOwnProperty(path)) {
+            console.error('[GOOEY] gooey.hot.' + caller + ' must be called from the top level of a hot reloaded file.');
+            return undefined;
+        }
+        return modules[path];
+    }
+
+    // Calls the dispose handlers of a module and returns the data they saved.
+    function disposeModule(path) {
+        let data = {};
+        if (modules.hasOwnProperty(path)) {
+            modules[path].disposers.forEach(function(fn) { fn(data); });
+            delete modules[path];
+        }
+        return data;
+    }
+
+    function navigate() {
+        let page = window.location.pathname + window.location.search;
+        if (socket.readyState === OPEN) {
+            socket.send(JSON.stringify({GooeyMessage: 'gooey-client-navigate', GooeyContent: page}));
+        } else {
+            gooey.OnNavigate(page);
+        }
     }
 
+    // The back and forward buttons move between the pages of gooey.Navigate.
+    window.addEventListener('popstate', function() {
+        navigate();
+    });
+
     let timeoutID = window.setInterval(function () {
         if (socket.readyState === CLOSED) {
             window.clearInterval(timeoutID);
@@ -45,59 +167,244 @@
         }
     }, 1500);
 
+    // When the server is run by the gooey dev command it is restarted on every
+    // rebuild.  Once the server is back the page is refreshed.
+    let devMode = false;
+
+    socket.addEventListener('close', function(evt) {
+        if (!devMode && evt.code !== 1012) {
+            return;
+        }
+        console.log('[GOOEY] Server is restarting, waiting to reconnect.');
+        let retryID = window.setInterval(function() {
+            let req = new XMLHttpRequest();
+            req.open('GET', window.location.href, true);
+            req.onload = function() {
+                window.clearInterval(retryID);
+                window.location.reload();
+            };
+            req.send();
+        }, 500);
+    });
+
     socket.addEventListener('open', function() {
         gooey.IsDisconnected = false;
         gooey.OnOpen();
     });
 
-    socket.addEventListener('message', function(wsevt) {
-        let data     = JSON.parse(wsevt.data);
-        let doReload = (data.hasOwnProperty('GooeyMessage') &&
-                        data.hasOwnProperty('GooeyContent') &&
-                        data.GooeyMessage === 'gooey-server-reload-content');
-
-        if (doReload) {
-            let cnt = data.GooeyContent;
-
-            function replaceJS(js) {
-                // Unlike a style tag, we can't just replace the inner HTML
-                // of the current script tag and have it reload.  Instead,
-                // yank it out of the DOM and put it back in.
-                let s = document.createElement('script');
-                s.id = "gooey-reload-js-content";
-                s.innerHTML = js;
-                document.head.appendChild(s);
-            }
+    // The hot reloaded CSS and Javascript files keyed by their path, each along
+    // with the <style> or <script> element made from it, and the order of the
+    // files last received from the server.
+    let reloaded = {};
+    let order    = [];
 
-            // The style and script calls have to be duplicated because for
-            // some odd reason, the 'if (script) {' statement below would
-            // report an error of not being defined!
-            if (cnt.Body !== "") {
-                document.body.innerHTML = cnt.Body;
-                let script = document.getElementById("gooey-reload-js-content");
-                if (script) {
-                    document.head.removeChild(script);
-                    replaceJS(script.innerHTML);
+    function reloadElement(file) {
+        let elt = undefined;
+        if (file.Type === 'css') {
+            elt = document.createElement('style');
+            elt.textContent = file.Content + '\n/*# sourceURL=' + file.Path + ' */';
+        } else {
+            elt = document.createElement('script');
+            elt.textContent = file.Content + '\n//# sourceURL=' + file.Path;
+        }
+        elt.setAttribute('data-gooey-file', file.Path);
+        if (nonce) {
+            elt.nonce = nonce;
+        }
+        return elt;
+    }
+
+    // The errors of the last reload are shown in an overlay on the page until
+    // it is dismissed or the next reload succeeds.  The server reports the
+    // files it failed to load and the client adds the errors thrown while
+    // running the reloaded scripts.  When run by the gooey dev command, the
+    // errors of a failed Go build are shown along with them until a build
+    // succeeds.
+    let serverErrors = [];
+    let reloadErrors = [];
+    let buildErrors  = [];
+    let overlay      = undefined;
+
+    function showErrors() {
+        if (overlay) {
+            overlay.remove();
+            overlay = undefined;
+        }
+        let errors = buildErrors.concat(reloadErrors);
+        if (errors.length === 0) {
+            return;
+        }
+
+        // Styles are set through the style property rather than a <style> tag
+        // or attribute to remain allowed under a Content-Security-Policy.
+        overlay = document.createElement('div');
+        overlay.id = 'gooey-error-overlay';
+        let style = overlay.style;
+        style.position   = 'fixed';
+        style.top        = '0';
+        style.left       = '0';
+        style.right      = '0';
+        style.maxHeight  = '50%';
+        style.overflow   = 'auto';
+        style.zIndex     = '2147483647';
+        style.padding    = '12px 16px';
+        style.background = 'rgba(60, 0, 0, 0.92)';
+        style.color      = '#fdd';
+        style.fontFamily = 'monospace';
+        style.fontSize   = '13px';
+        style.whiteSpace = 'pre-wrap';
+
+        let dismiss = document.createElement('button');
+        dismiss.textContent = 'Dismiss';
+        dismiss.style.float = 'right';
+        dismiss.addEventListener('click', function() {
+            buildErrors  = [];
+            reloadErrors = [];
+            showErrors();
+        });
+        overlay.appendChild(dismiss);
+
+        let title = document.createElement('div');
+        title.textContent = buildErrors.length > 0 ? '[GOOEY] Build failed' : '[GOOEY] Reload failed';
+        title.style.fontWeight   = 'bold';
+        title.style.marginBottom = '8px';
+        overlay.appendChild(title);
+
+        errors.forEach(function(err) {
+            let line = document.createElement('div');
+            line.textContent = err.File + (err.Line ? ':' + err.Line : '') + ' -- ' + err.Message;
+            overlay.appendChild(line);
+        });
+        document.body.appendChild(overlay);
+    }
+
+    function reloadContent(cnt) {
+        let changed = {};
+
+        (cnt.Events || []).forEach(function(evt) {
+            gooey.OnReload(evt);
+        });
+        if (cnt.Reload) {
+            window.location.reload();
+            return;
+        }
+
+        if (cnt.Body !== "") {
+            // Every script is run again so it can act on the new body.
+            Object.keys(reloaded).forEach(function(path) {
+                if (reloaded[path].file.Type === 'js') {
+                    changed[path] = reloaded[path].file;
                 }
+            });
+        }
+
+        let removed = [];
+        (cnt.Files || []).forEach(function(file) {
+            if (file.Removed) {
+                removed.push(file.Path);
+                delete changed[file.Path];
+            } else {
+                changed[file.Path] = file;
             }
-            if (cnt.CSS !== "") {
-                let style = document.getElementById("gooey-reload-css-content");
-                if (style) {
-                    style.innerHTML = cnt.CSS;
-                } else {
-                    style = document.createElement('style');
-                    style.id = "gooey-reload-css-content";
-                    style.innerHTML = cnt.CSS;
-                    document.head.appendChild(style);
-                }
+        });
+
+        // A script that is already running can only be replaced or removed if
+        // it accepts hot replacement, otherwise the whole page is reloaded.
+        let replaced = Object.keys(changed).concat(removed).filter(function(path) {
+            return reloaded.hasOwnProperty(path) && reloaded[path].file.Type === 'js';
+        });
+        let reload = replaced.some(function(path) {
+            return !modules.hasOwnProperty(path) || !modules[path].accepted;
+        });
+        if (reload) {
+            window.location.reload();
+            return;
+        }
+
+        let data = {};
+        replaced.forEach(function(path) {
+            data[path] = disposeModule(path);
+        });
+        removed.forEach(function(path) {
+            if (reloaded.hasOwnProperty(path)) {
+                reloaded[path].element.remove();
+                delete reloaded[path];
+            }
+        });
+
+        if (cnt.Errors) {
+            serverErrors = cnt.Errors;
+        }
+        if (cnt.Body !== "") {
+            document.body.innerHTML = cnt.Body;
+        }
+        if (cnt.Order) {
+            order = cnt.Order;
+        }
+
+        // Errors thrown by a script as it runs are reported to window's error
+        // handlers while the script's element is being appended.
+        let scriptErrors = [];
+        let running      = undefined;
+        let onError      = function(evt) {
+            if (running !== undefined) {
+                scriptErrors.push({File: running, Line: evt.lineno, Message: evt.message});
             }
-            if (cnt.Javascript !== "") {
-                let script = document.getElementById("gooey-reload-js-content");
-                if (script) {
-                    document.head.removeChild(script);
+        };
+        window.addEventListener('error', onError);
+
+        // Unlike a style tag, we can't just replace the content of a script tag
+        // and have it run again.  Instead, a changed file gets a new element.
+        // Appending the elements in order runs the new scripts in that order
+        // and only moves the unchanged elements, which doesn't run them again.
+        order.forEach(function(path) {
+            let entry = reloaded[path];
+            if (changed.hasOwnProperty(path)) {
+                if (entry) {
+                    entry.element.remove();
+                }
+                entry = {file: changed[path], element: reloadElement(changed[path])};
+                reloaded[path] = entry;
+                if (entry.file.Type === 'js') {
+                    modules[path] = {accepted: false, disposers: [], data: data[path]};
                 }
-                replaceJS(cnt.Javascript);
             }
+            if (entry) {
+                running = path;
+                document.head.appendChild(entry.element);
+                running = undefined;
+            }
+            if (modules.hasOwnProperty(path)) {
+                delete modules[path].data;
+            }
+        });
+
+        window.removeEventListener('error', onError);
+        reloadErrors = serverErrors.concat(scriptErrors);
+        showErrors();
+    }
+
+    socket.addEventListener('message', function(wsevt) {
+        let data     = JSON.parse(wsevt.data);
+        let isGooey  = (data !== null && typeof data === 'object' &&
+                        data.hasOwnProperty('GooeyMessage') &&
+                        data.hasOwnProperty('GooeyContent'));
+        let doReload = isGooey && data.GooeyMessage === 'gooey-server-reload-content';
+
+        if (isGooey && data.GooeyMessage === 'gooey-server-dev') {
+            devMode = true;
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-navigate') {
+            if (data.GooeyContent.Body !== '') {
+                document.body.innerHTML = data.GooeyContent.Body;
+            }
+            gooey.OnNavigate(data.GooeyContent.Page);
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-build-errors') {
+            buildErrors = data.GooeyContent;
+            showErrors();
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-error') {
+            gooey.OnError(data.GooeyContent);
+        } else if (doReload) {
+            reloadContent(data.GooeyContent);
         } else {
             gooey.OnMessage(data);
         }
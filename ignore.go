@@ -0,0 +1,200 @@
+package gooey
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A list of patterns that follow the rules of a .gitignore file.
+type ignoreRules []ignoreRule
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// Parses the gitignore style patterns.  Blank patterns and patterns starting
+// with a # are skipped.
+func parseIgnoreRules(patterns []string) (ignoreRules, error) {
+	var rules ignoreRules
+
+	for _, p := range patterns {
+		rule := ignoreRule{pattern: p}
+
+		p = strings.TrimRight(p, " \t\r")
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		} else if strings.HasPrefix(p, `\!`) || strings.HasPrefix(p, `\#`) {
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		if p == "" {
+			continue
+		}
+
+		// A pattern with a slash anywhere but at its end is relative to the root,
+		// otherwise it matches a name at any depth.
+		anchored := strings.Contains(p, "/")
+		p = strings.TrimPrefix(p, "/")
+
+		expr := globToRegexp(p)
+		if anchored || strings.HasPrefix(p, "**") {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(?:.*/)?" + expr + "$"
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ignore pattern %q -- %s", rule.pattern, err)
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Converts a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+	var (
+		expr strings.Builder
+		n    = len(glob)
+	)
+
+	for i := 0; i < n; i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && i+1 < n && glob[i+1] == '*':
+			atStart := i == 0 || glob[i-1] == '/'
+			i++
+			if atStart && i+1 < n && glob[i+1] == '/' {
+				// A leading "**/" or a "/**/" matches zero or more directories.
+				i++
+				expr.WriteString("(?:.*/)?")
+			} else {
+				expr.WriteString(".*")
+			}
+
+		case c == '*':
+			expr.WriteString("[^/]*")
+
+		case c == '?':
+			expr.WriteString("[^/]")
+
+		case c == '\\' && i+1 < n:
+			i++
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expr.String()
+}
+
+// Reports whether the slash separated path, relative to the root the rules apply
+// to, is ignored.  As with git, a path within an ignored directory is ignored
+// even if a later negated pattern matches the path.
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	if len(rules) == 0 || rel == "." || rel == "" {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if rules.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.match(rel, isDir)
+}
+
+// Returns whether the last rule matching the path ignores it.
+func (rules ignoreRules) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// Reads the patterns of the gitignore style file at path.  A missing file has
+// no patterns.
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		lines   []string
+		scanner = bufio.NewScanner(file)
+	)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Returns a function that reports whether an absolute path within root is
+// ignored by the server's ReloadIgnorePatterns or the patterns of the
+// .gooeyignore file found in root.
+func (s *Server) reloadIgnorer(root string) (func(path string, isDir bool) bool, error) {
+	patterns := append([]string(nil), s.ReloadIgnorePatterns...)
+
+	lines, err := readIgnoreFile(filepath.Join(root, ".gooeyignore"))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read .gooeyignore -- %s", err)
+	}
+	patterns = append(patterns, lines...)
+
+	rules, err := parseIgnoreRules(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(path string, isDir bool) bool {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return false
+		}
+		return rules.ignored(filepath.ToSlash(rel), isDir)
+	}, nil
+}
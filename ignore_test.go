@@ -0,0 +1,102 @@
+package gooey
+
+import "testing"
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		ignored  bool
+	}{
+		// A pattern without a slash matches a name at any depth.
+		{[]string{"*.log"}, "a.log", false, true},
+		{[]string{"*.log"}, "dir/sub/b.log", false, true},
+		{[]string{"*.log"}, "a.logx", false, false},
+		{[]string{"?.js"}, "a.js", false, true},
+		{[]string{"?.js"}, "ab.js", false, false},
+		{[]string{"?.js"}, "dir/b.js", false, true},
+
+		// A leading or middle slash anchors the pattern to the root.
+		{[]string{"/build"}, "build", true, true},
+		{[]string{"/build"}, "src/build", true, false},
+		{[]string{"doc/*.txt"}, "doc/a.txt", false, true},
+		{[]string{"doc/*.txt"}, "doc/sub/a.txt", false, false},
+		{[]string{"doc/*.txt"}, "x/doc/a.txt", false, false},
+
+		// A trailing slash only matches directories, at any depth.
+		{[]string{"build/"}, "build", true, true},
+		{[]string{"build/"}, "build", false, false},
+		{[]string{"build/"}, "src/build", true, true},
+		{[]string{"build/"}, "src/build", false, false},
+
+		// Everything within an ignored directory is ignored.
+		{[]string{"/build"}, "build/app.js", false, true},
+		{[]string{"build/"}, "src/build/deep/app.js", false, true},
+
+		// ** matches any number of directories.
+		{[]string{"**/foo"}, "foo", false, true},
+		{[]string{"**/foo"}, "a/b/foo", false, true},
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"a/**/b"}, "ab", false, false},
+		{[]string{"a/**/b"}, "x/a/b", false, false},
+		{[]string{"abc/**"}, "abc/x", false, true},
+		{[]string{"abc/**"}, "abc/x/y", false, true},
+		{[]string{"abc/**"}, "abc", true, false},
+
+		// A negated pattern makes a previously ignored path not ignored.
+		{[]string{"*.js", "!keep.js"}, "a.js", false, true},
+		{[]string{"*.js", "!keep.js"}, "keep.js", false, false},
+		{[]string{"*.js", "!keep.js"}, "dir/keep.js", false, false},
+		{[]string{"!keep.js", "*.js"}, "keep.js", false, true},
+		{[]string{"/*", "!/src"}, "src/app.js", false, false},
+		{[]string{"/*", "!/src"}, "other", false, true},
+
+		// But not a path within an ignored directory.
+		{[]string{"node_modules/", "!node_modules/keep.js"}, "node_modules/keep.js", false, true},
+		{[]string{"node_modules/", "!keep.js"}, "node_modules/keep.js", false, true},
+
+		// Character classes.
+		{[]string{"*.[oa]"}, "x.o", false, true},
+		{[]string{"*.[oa]"}, "x.c", false, false},
+		{[]string{"[!ab].txt"}, "c.txt", false, true},
+		{[]string{"[!ab].txt"}, "a.txt", false, false},
+		{[]string{"[a-c].txt"}, "b.txt", false, true},
+		{[]string{"[a-c].txt"}, "d.txt", false, false},
+		{[]string{"a["}, "a[", false, true},
+
+		// Escapes, comments, blank lines, and trailing spaces.
+		{[]string{`\!important`}, "!important", false, true},
+		{[]string{`\#file`}, "#file", false, true},
+		{[]string{`a\*`}, "a*", false, true},
+		{[]string{`a\*`}, "ab", false, false},
+		{[]string{"# comment"}, "# comment", false, false},
+		{[]string{"", "   "}, "a", false, false},
+		{[]string{"foo  "}, "foo", false, true},
+		{[]string{"foo.js"}, "foo_js", false, false},
+
+		// The root itself is never ignored.
+		{[]string{"*"}, ".", true, false},
+		{[]string{"*"}, "", true, false},
+		{nil, "a.js", false, false},
+	}
+
+	for _, test := range tests {
+		rules, err := parseIgnoreRules(test.patterns)
+		if err != nil {
+			t.Errorf("parseIgnoreRules(%q) failed -- %s", test.patterns, err)
+			continue
+		}
+		if got := rules.ignored(test.path, test.isDir); got != test.ignored {
+			t.Errorf("%q ignored(%q, isDir=%v) = %v, want %v", test.patterns, test.path, test.isDir, got, test.ignored)
+		}
+	}
+}
+
+func TestIgnoreRulesInvalid(t *testing.T) {
+	if _, err := parseIgnoreRules([]string{"[z-a]"}); err == nil {
+		t.Errorf("parseIgnoreRules accepted a character class with an invalid range")
+	}
+}
@@ -7,10 +7,329 @@ const INDEX = `
 <meta charset="utf-8">
 <title>Gooey App</title>
 <script>
-(function(){var e=window.location.origin.substring(7),d=new WebSocket("ws://"+e+"/gooeywebsocket"),a=void 0;window.hasOwnProperty("gooey")?a=window.gooey:(a={},window.gooey=a,a.OnMessage=function(a){console.log(a)},a.Send=function(a){1===d.readyState?d.send(JSON.stringify(a)):console.error("[GOOEY] Websocket connection is not open.")},a.IsDisconnected=!1,a.OnOpen=function(){console.log("[GOOEY] Websocket connection is open.")},a.OnDisconnect=function(){console.error("[GOOEY] Disconnected from server.")},
-a.OpenNewTab=function(){var a=new XMLHttpRequest;a.open("GET",window.location+"gooeynewtab",!0);a.send()});var f=window.setInterval(function(){3===d.readyState&&(window.clearInterval(f),a.IsDisconnected=!0,a.OnDisconnect())},1500);d.addEventListener("open",function(){a.IsDisconnected=!1;a.OnOpen()});d.addEventListener("message",function(d){var b=JSON.parse(d.data);if(b.hasOwnProperty("GooeyMessage")&&b.hasOwnProperty("GooeyContent")&&"gooey-server-reload-content"===b.GooeyMessage){d=function(a){var b=
-document.createElement("script");b.id="gooey-reload-js-content";b.innerHTML=a;document.head.appendChild(b)};b=b.GooeyContent;if(""!==b.Body){document.body.innerHTML=b.Body;var c=document.getElementById("gooey-reload-js-content");c&&(document.head.removeChild(c),d(c.innerHTML))}""!==b.CSS&&((c=document.getElementById("gooey-reload-css-content"))?c.innerHTML=b.CSS:(c=document.createElement("style"),c.id="gooey-reload-css-content",c.innerHTML=b.CSS,document.head.appendChild(c)));""!==b.Javascript&&((c=
-document.getElementById("gooey-reload-js-content"))&&document.head.removeChild(c),d(b.Javascript))}else a.OnMessage(b)})})();
+(function () {
+    const CONNECTING = 0;
+    const OPEN       = 1;
+    const CLOSING    = 2;
+    const CLOSED     = 3;
+    let params = new URLSearchParams(window.location.search);
+    if (params.has('gooeywindow')) {
+        window.name = params.get('gooeywindow');
+    }
+    let scheme   = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+    let socket   = new WebSocket(scheme + window.location.host + '/gooeywebsocket' +
+                                 '?window=' + encodeURIComponent(window.name) +
+                                 '&page=' + encodeURIComponent(window.location.pathname + window.location.search));
+    let gooey    = undefined;
+    let nonceScript = document.currentScript || document.querySelector('script[nonce]');
+    let nonce       = nonceScript ? nonceScript.nonce : '';
+    if (window.hasOwnProperty("gooey")) {
+        gooey = window.gooey;
+    } else {
+        gooey = {};
+        window.gooey = gooey;
+        gooey.OnMessage = function(msg) { console.log(msg); };
+        gooey.OnReload = function(evt) {
+            console.log('[GOOEY] Reload event ' + evt.Event + ' for ' + evt.Path);
+        };
+        gooey.OnNavigate = function(page) {
+            console.log('[GOOEY] Navigated to ' + page);
+        };
+        gooey.OnError = function(err) {
+            console.error('[GOOEY] ' + err.Code + ': ' + err.Message);
+        };
+        gooey.Send = function(payload) {
+            if (socket.readyState === OPEN) {
+                socket.send(JSON.stringify(payload));
+            } else {
+                console.error('[GOOEY] Websocket connection is not open.');
+            }
+        };
+        gooey.IsDisconnected = false;
+        gooey.OnOpen = function() {
+            console.log('[GOOEY] Websocket connection is open.');
+        };
+        gooey.OnDisconnect = function() {
+            console.error('[GOOEY] Disconnected from server.');
+        };
+        gooey.OpenNewTab = function() {
+            let req = new XMLHttpRequest();
+            req.open('GET', window.location.origin + '/gooeynewtab', true);
+            req.send();
+        };
+        gooey.Navigate = function(path) {
+            window.history.pushState(null, '', path);
+            navigate();
+        };
+        gooey.OpenWindow = function(name, path, params) {
+            let query = new URLSearchParams(params || {});
+            let url   = new URL(path, window.location.origin);
+            query.forEach(function(value, key) {
+                url.searchParams.append(key, value);
+            });
+            url.searchParams.set('gooeywindow', name);
+            if (window.open(url.toString(), name) === null) {
+                let req = new XMLHttpRequest();
+                req.open('GET', window.location.origin + '/gooeynewtab' +
+                         '?window=' + encodeURIComponent(name) +
+                         '&path=' + encodeURIComponent(path) +
+                         '&query=' + encodeURIComponent(query.toString()), true);
+                req.send();
+            }
+        };
+        gooey.hot = {
+            accept: function(fn) {
+                let mod = currentModule('accept');
+                if (mod) {
+                    mod.accepted = true;
+                    if (fn && mod.data !== undefined) {
+                        fn(mod.data);
+                    }
+                }
+            },
+            dispose: function(fn) {
+                let mod = currentModule('dispose');
+                if (mod) {
+                    mod.disposers.push(fn);
+                }
+            }
+        };
+    }
+    let modules = {};
+    function currentModule(caller) {
+        let script = document.currentScript;
+        let path   = script ? script.getAttribute('data-gooey-file') : null;
+        if (path === null || !modules.hasOwnProperty(path)) {
+            console.error('[GOOEY] gooey.hot.' + caller + ' must be called from the top level of a hot reloaded file.');
+            return undefined;
+        }
+        return modules[path];
+    }
+    function disposeModule(path) {
+        let data = {};
+        if (modules.hasOwnProperty(path)) {
+            modules[path].disposers.forEach(function(fn) { fn(data); });
+            delete modules[path];
+        }
+        return data;
+    }
+    function navigate() {
+        let page = window.location.pathname + window.location.search;
+        if (socket.readyState === OPEN) {
+            socket.send(JSON.stringify({GooeyMessage: 'gooey-client-navigate', GooeyContent: page}));
+        } else {
+            gooey.OnNavigate(page);
+        }
+    }
+    window.addEventListener('popstate', function() {
+        navigate();
+    });
+    let timeoutID = window.setInterval(function () {
+        if (socket.readyState === CLOSED) {
+            window.clearInterval(timeoutID);
+            gooey.IsDisconnected = true;
+            gooey.OnDisconnect();
+        }
+    }, 1500);
+    let devMode = false;
+    socket.addEventListener('close', function(evt) {
+        if (!devMode && evt.code !== 1012) {
+            return;
+        }
+        console.log('[GOOEY] Server is restarting, waiting to reconnect.');
+        let retryID = window.setInterval(function() {
+            let req = new XMLHttpRequest();
+            req.open('GET', window.location.href, true);
+            req.onload = function() {
+                window.clearInterval(retryID);
+                window.location.reload();
+            };
+            req.send();
+        }, 500);
+    });
+    socket.addEventListener('open', function() {
+        gooey.IsDisconnected = false;
+        gooey.OnOpen();
+    });
+    let reloaded = {};
+    let order    = [];
+    function reloadElement(file) {
+        let elt = undefined;
+        if (file.Type === 'css') {
+            elt = document.createElement('style');
+            elt.textContent = file.Content + '\n/*# sourceURL=' + file.Path + ' */';
+        } else {
+            elt = document.createElement('script');
+            elt.textContent = file.Content + '\n//# sourceURL=' + file.Path;
+        }
+        elt.setAttribute('data-gooey-file', file.Path);
+        if (nonce) {
+            elt.nonce = nonce;
+        }
+        return elt;
+    }
+    let serverErrors = [];
+    let reloadErrors = [];
+    let buildErrors  = [];
+    let overlay      = undefined;
+    function showErrors() {
+        if (overlay) {
+            overlay.remove();
+            overlay = undefined;
+        }
+        let errors = buildErrors.concat(reloadErrors);
+        if (errors.length === 0) {
+            return;
+        }
+        overlay = document.createElement('div');
+        overlay.id = 'gooey-error-overlay';
+        let style = overlay.style;
+        style.position   = 'fixed';
+        style.top        = '0';
+        style.left       = '0';
+        style.right      = '0';
+        style.maxHeight  = '50%';
+        style.overflow   = 'auto';
+        style.zIndex     = '2147483647';
+        style.padding    = '12px 16px';
+        style.background = 'rgba(60, 0, 0, 0.92)';
+        style.color      = '#fdd';
+        style.fontFamily = 'monospace';
+        style.fontSize   = '13px';
+        style.whiteSpace = 'pre-wrap';
+        let dismiss = document.createElement('button');
+        dismiss.textContent = 'Dismiss';
+        dismiss.style.float = 'right';
+        dismiss.addEventListener('click', function() {
+            buildErrors  = [];
+            reloadErrors = [];
+            showErrors();
+        });
+        overlay.appendChild(dismiss);
+        let title = document.createElement('div');
+        title.textContent = buildErrors.length > 0 ? '[GOOEY] Build failed' : '[GOOEY] Reload failed';
+        title.style.fontWeight   = 'bold';
+        title.style.marginBottom = '8px';
+        overlay.appendChild(title);
+        errors.forEach(function(err) {
+            let line = document.createElement('div');
+            line.textContent = err.File + (err.Line ? ':' + err.Line : '') + ' -- ' + err.Message;
+            overlay.appendChild(line);
+        });
+        document.body.appendChild(overlay);
+    }
+    function reloadContent(cnt) {
+        let changed = {};
+        (cnt.Events || []).forEach(function(evt) {
+            gooey.OnReload(evt);
+        });
+        if (cnt.Reload) {
+            window.location.reload();
+            return;
+        }
+        if (cnt.Body !== "") {
+            Object.keys(reloaded).forEach(function(path) {
+                if (reloaded[path].file.Type === 'js') {
+                    changed[path] = reloaded[path].file;
+                }
+            });
+        }
+        let removed = [];
+        (cnt.Files || []).forEach(function(file) {
+            if (file.Removed) {
+                removed.push(file.Path);
+                delete changed[file.Path];
+            } else {
+                changed[file.Path] = file;
+            }
+        });
+        let replaced = Object.keys(changed).concat(removed).filter(function(path) {
+            return reloaded.hasOwnProperty(path) && reloaded[path].file.Type === 'js';
+        });
+        let reload = replaced.some(function(path) {
+            return !modules.hasOwnProperty(path) || !modules[path].accepted;
+        });
+        if (reload) {
+            window.location.reload();
+            return;
+        }
+        let data = {};
+        replaced.forEach(function(path) {
+            data[path] = disposeModule(path);
+        });
+        removed.forEach(function(path) {
+            if (reloaded.hasOwnProperty(path)) {
+                reloaded[path].element.remove();
+                delete reloaded[path];
+            }
+        });
+        if (cnt.Errors) {
+            serverErrors = cnt.Errors;
+        }
+        if (cnt.Body !== "") {
+            document.body.innerHTML = cnt.Body;
+        }
+        if (cnt.Order) {
+            order = cnt.Order;
+        }
+        let scriptErrors = [];
+        let running      = undefined;
+        let onError      = function(evt) {
+            if (running !== undefined) {
+                scriptErrors.push({File: running, Line: evt.lineno, Message: evt.message});
+            }
+        };
+        window.addEventListener('error', onError);
+        order.forEach(function(path) {
+            let entry = reloaded[path];
+            if (changed.hasOwnProperty(path)) {
+                if (entry) {
+                    entry.element.remove();
+                }
+                entry = {file: changed[path], element: reloadElement(changed[path])};
+                reloaded[path] = entry;
+                if (entry.file.Type === 'js') {
+                    modules[path] = {accepted: false, disposers: [], data: data[path]};
+                }
+            }
+            if (entry) {
+                running = path;
+                document.head.appendChild(entry.element);
+                running = undefined;
+            }
+            if (modules.hasOwnProperty(path)) {
+                delete modules[path].data;
+            }
+        });
+        window.removeEventListener('error', onError);
+        reloadErrors = serverErrors.concat(scriptErrors);
+        showErrors();
+    }
+    socket.addEventListener('message', function(wsevt) {
+        let data     = JSON.parse(wsevt.data);
+        let isGooey  = (data !== null && typeof data === 'object' &&
+                        data.hasOwnProperty('GooeyMessage') &&
+                        data.hasOwnProperty('GooeyContent'));
+        let doReload = isGooey && data.GooeyMessage === 'gooey-server-reload-content';
+        if (isGooey && data.GooeyMessage === 'gooey-server-dev') {
+            devMode = true;
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-navigate') {
+            if (data.GooeyContent.Body !== '') {
+                document.body.innerHTML = data.GooeyContent.Body;
+            }
+            gooey.OnNavigate(data.GooeyContent.Page);
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-build-errors') {
+            buildErrors = data.GooeyContent;
+            showErrors();
+        } else if (isGooey && data.GooeyMessage === 'gooey-server-error') {
+            gooey.OnError(data.GooeyContent);
+        } else if (doReload) {
+            reloadContent(data.GooeyContent);
+        } else {
+            gooey.OnMessage(data);
+        }
+    });
+})();
 </script>
 <script>
 (function() {
@@ -0,0 +1,249 @@
+package gooey
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrAlreadyRunning is returned by Server.Start when the server is in single
+// instance mode and another instance of the app is already running.  The command
+// line arguments of this process have been forwarded to the running instance.
+var ErrAlreadyRunning = errors.New("gooey: app is already running")
+
+// ArgsApp is an App that wishes to receive the command line arguments of later
+// launches of the app while the server is in single instance mode.  If the App
+// given to Server.Start also implements ArgsApp then ReceiveArgs is called, on its
+// own goroutine, with the arguments (excluding the program name) of every launch
+// that was forwarded to this instance.
+type ArgsApp interface {
+	ReceiveArgs(args []string)
+}
+
+// The lock file held by the running instance of an app.  As soon as the lock is
+// acquired the file is given the address of the instance's control socket along
+// with a secret that must be presented when connecting to it.  Arguments that are
+// forwarded before the instance's server is ready are held until it is.
+type instanceLock struct {
+	path     string
+	file     *os.File
+	secret   string
+	listener net.Listener
+	onArgs   func(args []string)
+	ready    chan struct{} // Closed once onArgs is set.
+	closed   chan struct{} // Closed once the lock is released.
+}
+
+// The message sent to the control socket of the running instance.
+type instanceRequest struct {
+	Secret string
+	Args   []string
+}
+
+type instanceReply struct {
+	OK bool
+}
+
+// Returns the path of the lock file for the app with the given name, found in the
+// user's runtime directory.
+func instancePath(name string) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		if d, err := os.UserCacheDir(); err == nil {
+			dir = d
+		} else {
+			dir = os.TempDir()
+		}
+	}
+	return filepath.Join(dir, "gooey-"+fileName(name)+".instance")
+}
+
+// Acquires the lock of the app with the given name and opens the instance's
+// control socket.  If another instance holds the lock then args are forwarded to
+// it and ErrAlreadyRunning is returned.  A lock is only taken over when its control
+// socket refuses connections, which means the instance that held it has exited,
+// or when it has been left without an address for some time.
+func acquireInstance(name string, args []string) (*instanceLock, error) {
+	path := instancePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("Failed to create directory for instance lock -- %s", err)
+	}
+
+	for attempt := 0; attempt < 50; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			secret, err := randomNonce()
+			if err != nil {
+				file.Close()
+				os.Remove(path)
+				return nil, fmt.Errorf("Failed to generate instance secret -- %s", err)
+			}
+			l := &instanceLock{
+				path:   path,
+				file:   file,
+				secret: secret,
+				ready:  make(chan struct{}),
+				closed: make(chan struct{}),
+			}
+			if err := l.listen(); err != nil {
+				l.release()
+				return nil, err
+			}
+			return l, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("Failed to create instance lock file -- %s", err)
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to read instance lock file -- %s", err)
+		}
+
+		if fields := strings.Fields(string(content)); len(fields) == 2 {
+			err := forwardArgs(fields[0], fields[1], args)
+			if err == nil {
+				return nil, ErrAlreadyRunning
+			} else if !connRefused(err) {
+				return nil, fmt.Errorf("Failed to forward arguments to the running instance -- %s", err)
+			}
+			removeLock(path, content)
+			continue
+		}
+
+		// The instance holding the lock is between creating the file and writing its
+		// address, which it does right away, or it died in between.
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > 5*time.Second {
+			removeLock(path, content)
+			continue
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return nil, fmt.Errorf("Failed to acquire instance lock, %s", path)
+}
+
+// Removes the stale lock file at path unless another instance has replaced it
+// since it was read as content.
+func removeLock(path string, content []byte) {
+	if now, err := ioutil.ReadFile(path); err == nil && bytes.Equal(now, content) {
+		os.Remove(path)
+	}
+}
+
+// WSAECONNREFUSED, the error Windows gives when a connection is refused.
+const wsaeconnrefused = 10061
+
+// Reports whether err is from a connection that was refused, which, unlike a
+// timeout or a rejected secret, shows that nothing is listening at the address.
+func connRefused(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	errno, ok := sysErr.Err.(syscall.Errno)
+	return ok && (errno == syscall.ECONNREFUSED || errno == wsaeconnrefused)
+}
+
+// Sends args to the control socket of the running instance at addr.
+func forwardArgs(addr, secret string, args []string) error {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if args == nil {
+		args = []string{}
+	}
+	if err := json.NewEncoder(conn).Encode(instanceRequest{Secret: secret, Args: args}); err != nil {
+		return err
+	}
+	var reply instanceReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return err
+	}
+	if !reply.OK {
+		return fmt.Errorf("Running instance refused the arguments")
+	}
+	return nil
+}
+
+// Opens the control socket and records its address in the lock file.
+func (l *instanceLock) listen() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("Failed to create instance control socket -- %s", err)
+	}
+	if _, err := fmt.Fprintln(l.file, listener.Addr().String(), l.secret); err != nil {
+		listener.Close()
+		return fmt.Errorf("Failed to write instance lock file -- %s", err)
+	}
+	l.listener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go l.handle(conn)
+		}
+	}()
+	return nil
+}
+
+// Passes each set of forwarded arguments, including those that arrived before,
+// to onArgs.
+func (l *instanceLock) serve(onArgs func(args []string)) {
+	l.onArgs = onArgs
+	close(l.ready)
+}
+
+func (l *instanceLock) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req instanceRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+	ok := subtle.ConstantTimeCompare([]byte(req.Secret), []byte(l.secret)) == 1
+	json.NewEncoder(conn).Encode(instanceReply{OK: ok})
+	if !ok {
+		return
+	}
+
+	select {
+	case <-l.ready:
+		l.onArgs(req.Args)
+	case <-l.closed:
+	}
+}
+
+// Closes the control socket and removes the lock file.
+func (l *instanceLock) release() {
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	close(l.closed)
+	l.file.Close()
+	os.Remove(l.path)
+}
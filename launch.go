@@ -0,0 +1,198 @@
+package gooey
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Launcher opens a window or tab onto a server's URL.  A Server uses its Launcher
+// when it starts, unless NoAutoOpen is set, and whenever a client calls
+// gooey.OpenNewTab.
+type Launcher interface {
+	// Launch opens url.  It should return once the browser has been started rather
+	// than wait for the browser to exit.  If the server's URL carries the token of a
+	// TokenAuth then url is the file URL of a page that redirects to the server's
+	// URL, which keeps the token out of the browser's command line.
+	Launch(url string) error
+}
+
+// DefaultBrowser launches the user's default web browser.  If the BROWSER
+// environment variable is set then it's taken as a colon separated list of
+// commands that are tried in order until one starts.  The URL replaces any %s
+// within a command, otherwise it's passed as the command's last argument.  If
+// BROWSER is not set then the platform's default opener is run (i.e. xdg-open,
+// open, or explorer.exe).
+type DefaultBrowser struct{}
+
+func (DefaultBrowser) Launch(url string) error {
+	env := os.Getenv("BROWSER")
+	if env == "" {
+		return startCommand(BROWSE, url)
+	}
+
+	var errs []string
+	for _, cmd := range strings.Split(env, string(os.PathListSeparator)) {
+		args := strings.Fields(cmd)
+		if len(args) == 0 {
+			continue
+		}
+		replaced := false
+		for i, a := range args {
+			if strings.Contains(a, "%s") {
+				args[i] = strings.Replace(a, "%s", url, -1)
+				replaced = true
+			}
+		}
+		if !replaced {
+			args = append(args, url)
+		}
+		err := startCommand(args[0], args[1:]...)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return startCommand(BROWSE, url)
+	}
+	return fmt.Errorf("No command in $BROWSER could be started -- %s", strings.Join(errs, "; "))
+}
+
+// BrowserCommand launches a specific browser binary, such as "firefox" or the
+// full path to a browser's executable, with the URL as its last argument.
+type BrowserCommand struct {
+	// The name or path of the browser executable.
+	Path string
+
+	// Arguments passed to the browser before the URL.
+	Args []string
+}
+
+func (b BrowserCommand) Launch(url string) error {
+	if b.Path == "" {
+		return fmt.Errorf("No browser path given to BrowserCommand")
+	}
+	return startCommand(b.Path, append(append([]string(nil), b.Args...), url)...)
+}
+
+// AppWindow launches Chromium or Google Chrome in app mode, which opens the URL in
+// a window without tabs, an address bar, or other browser chrome so that the gooey
+// app looks like a desktop application.
+type AppWindow struct {
+	// The name or path of the Chromium based browser to run.  If Path is the empty
+	// string then the common install locations of Chrome and Chromium are searched.
+	Path string
+
+	// The initial size of the window in pixels.  If either is zero or less then the
+	// browser chooses the window's size.
+	Width, Height int
+
+	// Additional arguments passed to the browser, such as "--user-data-dir=DIR" to
+	// keep the app's window separate from the user's browser profile.
+	Args []string
+}
+
+func (a AppWindow) Launch(url string) error {
+	path := a.Path
+	if path == "" {
+		for _, p := range appBrowsers {
+			if found, err := exec.LookPath(p); err == nil {
+				path = found
+				break
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("Failed to find Chrome or Chromium for an app window")
+		}
+	}
+
+	args := []string{"--app=" + url}
+	if a.Width > 0 && a.Height > 0 {
+		args = append(args, fmt.Sprintf("--window-size=%d,%d", a.Width, a.Height))
+	}
+	return startCommand(path, append(args, a.Args...)...)
+}
+
+// NoLauncher doesn't launch anything, leaving the user to open the server's URL.
+type NoLauncher struct{}
+
+func (NoLauncher) Launch(url string) error {
+	return nil
+}
+
+func startCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Failed to start %s -- %s", name, err)
+	}
+	// Reap the process once it exits.
+	go cmd.Wait()
+	return nil
+}
+
+// Opens url with the server's Launcher.
+func (s *Server) launch(url string) {
+	if !s.launchable {
+		s.infoln("Not opening a browser for a server that isn't listening on TCP")
+		return
+	}
+	if err := s.open(url); err != nil {
+		s.errorln("Failed to launch browser --", err)
+	}
+}
+
+// Passes u to the server's Launcher.  A URL carrying the token of a TokenAuth is
+// not passed as is, since the command line of the browser can be read by any
+// user of the machine, but through a redirect page in the server's temporary
+// directory that only the user can read.
+func (s *Server) open(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Query().Get("token") == "" {
+		return s.launcher().Launch(u)
+	}
+
+	file, err := ioutil.TempFile(s.dir, "redirect*.html")
+	if err != nil {
+		return fmt.Errorf("Failed to create redirect page -- %s", err)
+	}
+	defer file.Close()
+
+	err = redirectTemplate.Execute(file, struct{ URL string }{u})
+	if err != nil {
+		return fmt.Errorf("Failed to write redirect page -- %s", err)
+	}
+
+	// A file URL's path is absolute with forward slashes, even on Windows.
+	path := filepath.ToSlash(file.Name())
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return s.launcher().Launch((&url.URL{Scheme: "file", Path: path}).String())
+}
+
+// The page written by open, executed with the URL to redirect to as .URL.
+var redirectTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<script>(function(){window.location.replace({{.URL}});})()</script>
+</head>
+<body></body>
+</html>`))
+
+// Returns the server's Launcher or DefaultBrowser if it has none.
+func (s *Server) launcher() Launcher {
+	if s.Launcher != nil {
+		return s.Launcher
+	}
+	return DefaultBrowser{}
+}
@@ -0,0 +1,19 @@
+package gooey
+
+const LOGIN = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Failed}}<p><strong>{{.Failed}}</strong></p>{{end}}
+<form method="post" action="/gooeylogin">
+<input type="hidden" name="next" value="{{.Next}}">
+<p><label>Username <input type="text" name="user" autofocus></label></p>
+<p><label>Password <input type="password" name="password"></label></p>
+<p><button type="submit">Log In</button></p>
+</form>
+</body>
+</html>`
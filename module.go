@@ -0,0 +1,41 @@
+package gooey
+
+//go:generate go run setup.go client
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The paths the gooey client is served at so that pages built with a bundler or
+// TypeScript can import the client rather than inlining it as the default index
+// page does.  A page should load the client only once, either by import or inline.
+const (
+	ClientScriptPath = "/gooey/client.js"
+	ClientModulePath = "/gooey/client.mjs"
+	ClientTypesPath  = "/gooey/client.d.ts"
+)
+
+// Registers the handlers serving the client.
+func handleClient(mux *http.ServeMux) {
+	mux.HandleFunc(ClientScriptPath, serveClient(CLIENT_JS, "text/javascript; charset=utf-8"))
+	mux.HandleFunc(ClientModulePath, serveClient(CLIENT_MJS, "text/javascript; charset=utf-8"))
+	mux.HandleFunc(ClientTypesPath, serveClient(CLIENT_DTS, "application/typescript; charset=utf-8"))
+}
+
+// Serves a form of the client.  The client's version is its ETag so that browsers
+// revalidate their cached copy and only fetch it again once the client changes.
+func serveClient(content, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Content-Type", contentType)
+		header.Set("Cache-Control", "no-cache")
+		header.Set("ETag", `"`+CLIENT_VERSION+`"`)
+		if content == CLIENT_MJS {
+			// Lets tools such as Deno find the module's types.
+			header.Set("X-TypeScript-Types", ClientTypesPath)
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}
+}
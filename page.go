@@ -0,0 +1,87 @@
+package gooey
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Wraps the handler so that requests for the index page are served by the server
+// itself when the page needs to be altered for each request.  If TemplateData is
+// set then the page is executed as an html/template with the data, and if the
+// request has a CSP nonce then the nonce is added to every <script> and <style>
+// tag of the page.  Other requests, or if the index page doesn't exist, are passed
+// on to the handler.
+func (s *Server) serveIndex(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, _ := r.Context().Value(nonceKey{}).(string)
+		if (nonce == "" && s.TemplateData == nil) || (r.URL.Path != "/" && r.URL.Path != "/index.html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		page, err := ioutil.ReadFile(s.index)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				s.errorln("Failed to read", s.index, "--", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.writePage(w, r, "index.html", page)
+	})
+}
+
+// Writes the page, executing it as a template named name if TemplateData is set
+// and adding the request's CSP nonce, if any, to its <script> and <style> tags.
+// The nonce is added to the page's source before it is executed so that markup
+// coming from the template's data, which may not be trusted, is never allowed.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, name string, page []byte) {
+	if nonce, _ := r.Context().Value(nonceKey{}).(string); nonce != "" {
+		page = nonceTag.ReplaceAll(page, []byte(`<$1 nonce="`+nonce+`"$2`))
+	}
+	if s.TemplateData != nil {
+		var err error
+		if page, err = s.executeTemplate(name, page); err != nil {
+			s.errorln("Failed to render", name, "--", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(page)
+}
+
+// Parses src as an html/template named name and executes it with the value
+// returned from the server's TemplateData.
+func (s *Server) executeTemplate(name string, src []byte) ([]byte, error) {
+	tpl, err := template.New(name).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, s.TemplateData()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var templateLine = regexp.MustCompile(`^(?:html/)?template: [^:]*:(\d+)`)
+
+// Returns the line number given in an error from the template packages or zero
+// if there is none.
+func templateErrorLine(err error) int {
+	m := templateLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
@@ -0,0 +1,124 @@
+package gooey
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Listens on addr.  If RememberPort is set and addr is a TCP address that doesn't
+// give a port then the port remembered from the last run is tried first, falling
+// back to a port picked by the OS if it's taken.  The port listened on is then
+// remembered for the next run.
+func (s *Server) listen(network, addr string) (net.Listener, error) {
+	if network == "unix" {
+		return listenUnix(addr)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if s.RememberPort == "" || !strings.HasPrefix(network, "tcp") || err != nil || (port != "" && port != "0") {
+		return net.Listen(network, addr)
+	}
+
+	path, err := portPath(s.RememberPort)
+	if err != nil {
+		s.errorln("Failed to find where to remember the port --", err)
+		return net.Listen("tcp", addr)
+	}
+
+	saved := ""
+	if content, err := ioutil.ReadFile(path); err == nil {
+		saved = strings.TrimSpace(string(content))
+	} else if !os.IsNotExist(err) {
+		s.errorln("Failed to read remembered port --", err)
+	}
+
+	if saved != "" {
+		if listener, err := net.Listen(network, net.JoinHostPort(host, saved)); err == nil {
+			return listener, nil
+		}
+		s.infoln("Remembered port", saved, "is not available, picking another")
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, port, err := net.SplitHostPort(listener.Addr().String()); err == nil && port != saved {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			s.errorln("Failed to remember port --", err)
+		} else if err := ioutil.WriteFile(path, []byte(port+"\n"), 0600); err != nil {
+			s.errorln("Failed to remember port --", err)
+		}
+	}
+	return listener, nil
+}
+
+// Listens on the Unix domain socket at path.  A socket file left behind by a server
+// that didn't close its listener is removed first.
+func listenUnix(path string) (net.Listener, error) {
+	listener, err := net.Listen("unix", path)
+	if err == nil || strings.HasPrefix(path, "@") {
+		return listener, err
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil, err
+	}
+	if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// Returns the path of the file remembering the port of the app with the given name.
+func portPath(name string) (string, error) {
+	dir, err := userConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gooey", fileName(name)+".port"), nil
+}
+
+// Returns the user's configuration directory, i.e. $XDG_CONFIG_HOME or ~/.config
+// on Linux, ~/Library/Application Support on macOS, and %AppData% on Windows.
+func userConfigDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("AppData"); dir != "" {
+			return dir, nil
+		}
+		return "", fmt.Errorf("%%AppData%% is not defined")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, "Library", "Application Support"), nil
+		}
+	default:
+		if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+			return dir, nil
+		}
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, ".config"), nil
+		}
+	}
+	return "", fmt.Errorf("$HOME is not defined")
+}
+
+// Replaces the characters of name that aren't allowed in a file name.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
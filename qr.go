@@ -0,0 +1,482 @@
+package gooey
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A minimal QR code encoder for printing the server's URL to a terminal.  Text is
+// encoded in byte mode at error correction level L using the smallest of versions
+// 1 through 10 that fits, which allows up to 271 bytes of text.
+
+// The error correction block structure of versions 1 through 10 at level L.
+type qrVersion struct {
+	ecPerBlock int
+	blocks     []int // The number of data codewords in each block.
+	align      []int // The center coordinates of the alignment patterns.
+}
+
+var qrVersions = []qrVersion{
+	{7, []int{19}, nil},
+	{10, []int{34}, []int{6, 18}},
+	{15, []int{55}, []int{6, 22}},
+	{20, []int{80}, []int{6, 26}},
+	{26, []int{108}, []int{6, 30}},
+	{18, []int{68, 68}, []int{6, 34}},
+	{20, []int{78, 78}, []int{6, 22, 38}},
+	{24, []int{97, 97}, []int{6, 24, 42}},
+	{30, []int{116, 116}, []int{6, 26, 46}},
+	{18, []int{68, 68, 69, 69}, []int{6, 28, 50}},
+}
+
+type qrCode struct {
+	size     int
+	modules  [][]bool // True for a dark module, indexed by row then column.
+	function [][]bool // True for the modules of the function patterns.
+}
+
+// Encodes text as a QR code.
+func encodeQR(text string) (*qrCode, error) {
+	data := []byte(text)
+
+	version := 0
+	for v := range qrVersions {
+		countBits := 8
+		if v+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*sum(qrVersions[v].blocks) {
+			version = v + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("Text of %d bytes is too long for a QR code", len(data))
+	}
+
+	info := qrVersions[version-1]
+	size := 17 + 4*version
+	qr := &qrCode{
+		size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.function[i] = make([]bool, size)
+	}
+
+	qr.drawFunctionPatterns(version, info.align)
+	qr.drawCodewords(qrCodewords(data, version, info))
+
+	best, penalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormat(mask)
+		if p := qr.penalty(); penalty < 0 || p < penalty {
+			best, penalty = mask, p
+		}
+		qr.applyMask(mask) // Masking twice undoes the mask.
+	}
+	qr.applyMask(best)
+	qr.drawFormat(best)
+
+	return qr, nil
+}
+
+func sum(ns []int) int {
+	total := 0
+	for _, n := range ns {
+		total += n
+	}
+	return total
+}
+
+// Returns the interleaved data and error correction codewords of data.
+func qrCodewords(data []byte, version int, info qrVersion) []byte {
+	var bits qrBits
+	bits.append(0x4, 4) // Byte mode.
+	if version < 10 {
+		bits.append(len(data), 8)
+	} else {
+		bits.append(len(data), 16)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := 8 * sum(info.blocks)
+	for i := 0; i < 4 && len(bits) < capacity; i++ {
+		bits.append(0, 1)
+	}
+	for len(bits)%8 != 0 {
+		bits.append(0, 1)
+	}
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	var (
+		codewords = bits.bytes()
+		blocks    = make([][]byte, len(info.blocks))
+		ecBlocks  = make([][]byte, len(info.blocks))
+		divisor   = rsDivisor(info.ecPerBlock)
+	)
+	for i, n := range info.blocks {
+		blocks[i], codewords = codewords[:n], codewords[n:]
+		ecBlocks[i] = rsRemainder(blocks[i], divisor)
+	}
+
+	var result []byte
+	for i := 0; i < info.blocks[len(info.blocks)-1]; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				result = append(result, b[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			result = append(result, b[i])
+		}
+	}
+	return result
+}
+
+type qrBits []bool
+
+func (b *qrBits) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+func (b qrBits) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return result
+}
+
+// Multiplies two elements of GF(256) modulo the QR code polynomial 0x11D.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// Returns the coefficients, highest power first and excluding the leading one, of
+// the Reed-Solomon generator polynomial of the given degree.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// Returns the Reed-Solomon error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+func (qr *qrCode) set(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns(version int, align []int) {
+	n := qr.size
+
+	for i := 0; i < n; i++ {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+
+	// The finder patterns along with their separators.
+	for _, c := range [][2]int{{3, 3}, {n - 4, 3}, {3, n - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= n || y < 0 || y >= n {
+					continue
+				}
+				d := maxInt(absInt(dx), absInt(dy))
+				qr.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	last := len(align) - 1
+	for i, cx := range align {
+		for j, cy := range align {
+			// Skip the corners occupied by the finder patterns.
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(cx+dx, cy+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format areas, which are drawn once the mask is chosen.
+	qr.drawFormat(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>uint(i))&1 != 0
+			a, b := n-11+i%3, i/3
+			qr.set(a, b, dark)
+			qr.set(b, a, dark)
+		}
+	}
+}
+
+// Draws both copies of the format information for level L and the mask.
+func (qr *qrCode) drawFormat(mask int) {
+	data := 1<<3 | mask // Level L.
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	n := qr.size
+	for i := 0; i <= 5; i++ {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.set(n-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, n-15+i, bit(i))
+	}
+	qr.set(8, n-8, true)
+}
+
+// Places the codewords in the zigzag order of the QR code.
+func (qr *qrCode) drawCodewords(data []byte) {
+	n := qr.size
+	i := 0
+	for right := n - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < n; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = n - 1 - vert
+				}
+				if !qr.function[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = (data[i/8]>>uint(7-i%8))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.function[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// Scores how hard the code is to scan following the penalty rules of the QR code
+// specification, lower being better.
+func (qr *qrCode) penalty() int {
+	var (
+		n      = qr.size
+		result = 0
+		dark   = 0
+	)
+
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i <= n; i++ {
+			if i < n && get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				result += run - 2
+			}
+			run = 1
+		}
+
+		// A pattern like a finder, 1:1:3:1:1, with four light modules on one side.
+		pattern := []bool{true, false, true, true, true, false, true}
+		for i := 0; i+7 <= n; i++ {
+			match := true
+			for j, p := range pattern {
+				if get(i+j) != p {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			before, after := true, true
+			for j := 1; j <= 4; j++ {
+				if i-j >= 0 && get(i-j) {
+					before = false
+				}
+				if i+6+j < n && get(i+6+j) {
+					after = false
+				}
+			}
+			if before || after {
+				result += 40
+			}
+		}
+	}
+
+	for y := 0; y < n; y++ {
+		line(func(i int) bool { return qr.modules[y][i] })
+	}
+	for x := 0; x < n; x++ {
+		line(func(i int) bool { return qr.modules[i][x] })
+	}
+
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := qr.modules[y][x]
+				if c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	total := n * n
+	if k := (absInt(dark*20-total*10)+total-1)/total - 1; k > 0 {
+		result += k * 10
+	}
+
+	return result
+}
+
+// Writes the QR code as text made of Unicode block characters, two rows of modules
+// per line, surrounded by a quiet zone.  Light modules are drawn with blocks and dark
+// modules are left blank, so the code scans when the terminal draws light text on a
+// dark background.
+func (qr *qrCode) write(w io.Writer) error {
+	const quiet = 2
+
+	dark := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		if x < 0 || y < 0 || x >= qr.size || y >= qr.size {
+			return false
+		}
+		return qr.modules[y][x]
+	}
+
+	var (
+		b = &strings.Builder{}
+		n = qr.size + 2*quiet
+	)
+	for y := 0; y < n; y += 2 {
+		for x := 0; x < n; x++ {
+			top := !dark(x, y)
+			bottom := y+1 < n && !dark(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+package gooey
+
+import (
+	"math"
+	"time"
+)
+
+// A token bucket that allows a number of events per second with bursts of up
+// to a maximum number of events.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Returns a limiter allowing rate events per second with bursts of up to burst
+// events, or nil if rate is not positive.  If burst is not positive then the
+// burst is the rate rounded up.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(burst)
+	if burst <= 0 {
+		b = math.Ceil(rate)
+	}
+	return &rateLimiter{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// Reports whether an event may happen now, consuming a token if it may.  A nil
+// limiter allows every event.
+func (l *rateLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,45 @@
+package gooey
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+)
+
+// Returns the URL of the server listening on addr, which for a Unix domain socket
+// has the scheme "http+unix" and the escaped path of the socket as its host.  If
+// the server authenticates with a TokenAuth then the token is included in the URL
+// so that a browser opened with it is authenticated.
+func (s *Server) serverURL(scheme string, addr net.Addr) string {
+	u := scheme + "://" + addr.String() + "/"
+	if _, ok := addr.(*net.TCPAddr); !ok {
+		u = scheme + "+" + addr.Network() + "://" + url.PathEscape(addr.String()) + "/"
+	}
+	if a, ok := s.Authenticator.(*TokenAuth); ok && a.Token != "" {
+		u += "?token=" + url.QueryEscape(a.Token)
+	}
+	return u
+}
+
+// Announces that the server is listening at url through ReadyC and, if asked
+// for, by printing the URL and its QR code to stdout.
+func (s *Server) ready(url string) {
+	s.infoln("Listening at", url)
+
+	if s.PrintURL {
+		fmt.Println(url)
+	}
+	if s.PrintQRCode {
+		if qr, err := encodeQR(url); err != nil {
+			s.errorln("Failed to create QR code --", err)
+		} else if err := qr.write(os.Stdout); err != nil {
+			s.errorln("Failed to print QR code --", err)
+		}
+	}
+	if s.ReadyC != nil {
+		go func() {
+			s.ReadyC <- url
+		}()
+	}
+}
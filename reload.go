@@ -0,0 +1,530 @@
+package gooey
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/0xABAD/filewatch"
+)
+
+// ReloadAction is what a client does when a file matching a ReloadRule changes.
+type ReloadAction int
+
+const (
+	// Reload the whole page.
+	ReloadPage ReloadAction = iota
+
+	// Replace the file's <style> tag in the document.
+	ReloadCSS
+
+	// Replace the file's <script> tag in the document, see gooey.hot in gooey.js.
+	ReloadJS
+
+	// Replace the body of the document with the content of the file.
+	ReloadBody
+
+	// Call gooey.OnReload in the client with the rule's Event.
+	ReloadEvent
+
+	// Do nothing.
+	ReloadIgnore
+)
+
+// ReloadRule maps the files of ReloadWatchDir matching a pattern to the action
+// clients take when one of those files changes.
+type ReloadRule struct {
+	// The pattern a file must match for the rule to apply, using the same syntax
+	// as path.Match.  If the pattern contains a slash then it is matched against
+	// the slash separated path of the file relative to ReloadWatchDir, otherwise
+	// it is matched against the file's name.
+	Pattern string
+
+	Action ReloadAction
+
+	// The name of the event given to gooey.OnReload for the ReloadEvent action.
+	// If this field is the empty string then Pattern is used.
+	Event string
+}
+
+// The rules that apply after the server's ReloadRules.
+var defaultReloadRules = []ReloadRule{
+	{Pattern: "body.html", Action: ReloadBody},
+	{Pattern: "*.css", Action: ReloadCSS},
+	{Pattern: "*.js", Action: ReloadJS},
+}
+
+// The content sent to gooey.js to hot reload the page.  An empty Body means that
+// the body hasn't changed, Files holds only the CSS and Javascript files that have
+// changed, and Order, if non nil, lists the paths of every CSS and Javascript file
+// in the order their tags are to appear in the document.
+//
+// If Reload is true then the client reloads the whole page and Events holds the
+// events to be passed on to gooey.OnReload.  Errors, if non nil, lists every error
+// that currently prevents a file from being reloaded.
+type contentUpdate struct {
+	Body   string
+	Files  []reloadFile
+	Order  []string
+	Reload bool
+	Events []reloadEvent
+	Errors []reloadError
+}
+
+// An error that occurred while reloading a file, which is shown in an overlay on
+// the page.  Line is zero if the error isn't tied to a line of the file.
+type reloadError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// An event for gooey.OnReload caused by a change to the file at Path.
+type reloadEvent struct {
+	Event   string
+	Path    string
+	Removed bool
+}
+
+// A CSS or Javascript file to be placed in, or removed from, the document.
+type reloadFile struct {
+	Path    string // slash separated path relative to ReloadWatchDir
+	Type    string // either "css" or "js"
+	Content string
+	Removed bool
+}
+
+// Merges a later update into u so that u holds the latest content of both.
+func (u *contentUpdate) merge(next contentUpdate) {
+	if next.Body != "" {
+		u.Body = next.Body
+	}
+	for _, f := range next.Files {
+		replaced := false
+		for i := range u.Files {
+			if u.Files[i].Path == f.Path {
+				u.Files[i] = f
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			u.Files = append(u.Files, f)
+		}
+	}
+	if next.Order != nil {
+		u.Order = next.Order
+	}
+	u.Reload = u.Reload || next.Reload
+	u.Events = append(u.Events, next.Events...)
+	if next.Errors != nil {
+		u.Errors = next.Errors
+	}
+}
+
+func (u contentUpdate) empty() bool {
+	return u.Body == "" && len(u.Files) == 0 && u.Order == nil && !u.Reload &&
+		len(u.Events) == 0 && u.Errors == nil
+}
+
+// Watches the server's ReloadWatchDir once for every connection to the server.
+// The reload content is computed once for each change and is then fanned out to
+// every subscribed connection.
+type reloader struct {
+	server *Server
+
+	root     string
+	mu       sync.Mutex
+	body     string // the content, or template, of the body
+	bodyPath string
+	files    map[string]reloadFile
+	errors   map[string]reloadError
+	subs     map[*reloadSubscriber]struct{}
+}
+
+// A connection's subscription to the reloader.  Updates for the connection are
+// merged into pending and notify is signaled; the connection then takes the
+// pending update when it is ready to send it.  This way a slow connection never
+// holds up the reloader or the other connections.
+type reloadSubscriber struct {
+	notify  chan struct{}
+	mu      sync.Mutex
+	pending contentUpdate
+	index   bool // Whether the connection's page is the index page.
+}
+
+// Starts watching ReloadWatchDir until done is closed.
+func (s *Server) watchReloadDir(done <-chan struct{}) (*reloader, error) {
+	root, err := filepath.Abs(s.ReloadWatchDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ignore, err := s.reloadIgnorer(root)
+	if err != nil {
+		return nil, err
+	}
+
+	updates, err := s.watch(done, root, ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reloader{
+		server: s,
+		root:   root,
+		files:  make(map[string]reloadFile),
+		errors: make(map[string]reloadError),
+		subs:   make(map[*reloadSubscriber]struct{}),
+	}
+
+	// The first batch of updates holds every file in the directory.  Waiting on
+	// it here ensures that even the first connection receives all of the content.
+	select {
+	case us, ok := <-updates:
+		if ok {
+			r.update(us)
+		}
+	case <-done:
+	}
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case us, ok := <-updates:
+				if !ok {
+					return
+				}
+				r.update(us)
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+// Subscribes a connection to the reloader.  The subscriber immediately has the
+// current body, CSS, and Javascript content pending so a new connection receives
+// the full content of the watched directory.  The body is only sent to a
+// connection whose page is the index page, since it replaces that page's body.
+func (r *reloader) subscribe(index bool) *reloadSubscriber {
+	sub := &reloadSubscriber{notify: make(chan struct{}, 1), index: index}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files := make([]reloadFile, 0, len(r.files))
+	for _, p := range r.order() {
+		files = append(files, r.files[p])
+	}
+
+	body, _ := r.renderBody()
+
+	r.subs[sub] = struct{}{}
+	sub.push(contentUpdate{
+		Body:   body,
+		Files:  files,
+		Order:  r.order(),
+		Errors: r.errorList(),
+	})
+
+	return sub
+}
+
+func (r *reloader) unsubscribe(sub *reloadSubscriber) {
+	r.mu.Lock()
+	delete(r.subs, sub)
+	r.mu.Unlock()
+}
+
+// Returns the rendered body for a connection that navigates to the index page, or
+// the empty string if the watched directory has no body.
+func (r *reloader) indexBody() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := r.renderBody()
+	return body
+}
+
+// Merges the update into the subscriber's pending update and signals the
+// subscriber if it hasn't been already.
+func (sub *reloadSubscriber) push(u contentUpdate) {
+	sub.mu.Lock()
+	if !sub.index {
+		u.Body = ""
+	}
+	if u.empty() {
+		sub.mu.Unlock()
+		return
+	}
+	sub.pending.merge(u)
+	sub.mu.Unlock()
+
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Sets whether the connection's page is the index page, as it may change when the
+// connection navigates to another route.
+func (sub *reloadSubscriber) setIndex(index bool) {
+	sub.mu.Lock()
+	sub.index = index
+	if !index {
+		sub.pending.Body = ""
+	}
+	sub.mu.Unlock()
+}
+
+// Returns and clears the subscriber's pending update.
+func (sub *reloadSubscriber) take() contentUpdate {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	u := sub.pending
+	sub.pending = contentUpdate{}
+	return u
+}
+
+// Computes the reload content from the watched file updates and sends it to
+// every subscriber.
+func (r *reloader) update(updates []filewatch.Update) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u := r.reloadWebContent(updates)
+	for sub := range r.subs {
+		sub.push(u)
+	}
+}
+
+func (r *reloader) reloadWebContent(updates []filewatch.Update) contentUpdate {
+	var (
+		s             = r.server
+		update        contentUpdate
+		errorsChanged bool
+	)
+
+	// Reads the file and records any error so that it is reported to clients
+	// until the file is read successfully or is removed.
+	read := func(u filewatch.Update, rel string) ([]byte, error) {
+		content, err := ioutil.ReadFile(u.AbsPath)
+		if err != nil && !os.IsNotExist(err) {
+			s.errorln("Failed to read", u.AbsPath, "--", err)
+			r.errors[rel] = reloadError{File: rel, Message: err.Error()}
+			errorsChanged = true
+		} else if _, ok := r.errors[rel]; ok {
+			delete(r.errors, rel)
+			errorsChanged = true
+		}
+		return content, err
+	}
+
+	// Records the change of a CSS or Javascript file in both the known files and
+	// the update.
+	check := func(u filewatch.Update, rel, typ string) {
+		file := reloadFile{Path: rel, Type: typ}
+
+		if u.WasRemoved {
+			file.Removed = true
+		} else if content, err := read(u, rel); err != nil {
+			// This can occur for files that are created by other programs,
+			// such as a text editor, which may create backup files and
+			// delete them before the next filewatch update is received.
+			if os.IsNotExist(err) {
+				file.Removed = true
+			} else {
+				return
+			}
+		} else {
+			file.Content = string(content)
+		}
+
+		if file.Removed {
+			if _, ok := r.errors[rel]; ok {
+				delete(r.errors, rel)
+				errorsChanged = true
+			}
+			if _, ok := r.files[file.Path]; !ok {
+				return
+			}
+			delete(r.files, file.Path)
+		} else {
+			r.files[file.Path] = file
+		}
+		update.Files = append(update.Files, file)
+	}
+
+	for _, u := range updates {
+		if u.Next != nil && u.Next.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(r.root, u.AbsPath)
+		if err != nil {
+			s.errorln("Failed to find relative path of", u.AbsPath, "--", err)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		rule, ok := s.reloadRule(rel)
+		if !ok {
+			continue
+		}
+
+		switch rule.Action {
+		case ReloadPage:
+			update.Reload = true
+
+		case ReloadCSS:
+			check(u, rel, "css")
+
+		case ReloadJS:
+			check(u, rel, "js")
+
+		case ReloadBody:
+			if u.WasRemoved {
+				r.body = ""
+				update.Body = "<div></div>"
+				if _, ok := r.errors[rel]; ok {
+					delete(r.errors, rel)
+					errorsChanged = true
+				}
+			} else if body, err := read(u, rel); err == nil {
+				r.body, r.bodyPath = string(body), rel
+				body, changed := r.renderBody()
+				update.Body = body
+				errorsChanged = errorsChanged || changed
+			}
+
+		case ReloadEvent:
+			event := rule.Event
+			if event == "" {
+				event = rule.Pattern
+			}
+			update.Events = append(update.Events, reloadEvent{Event: event, Path: rel, Removed: u.WasRemoved})
+		}
+	}
+
+	if len(update.Files) > 0 {
+		update.Order = r.order()
+	}
+	if errorsChanged || !update.empty() {
+		update.Errors = r.errorList()
+	}
+
+	return update
+}
+
+// Returns the body to send to clients, which is the body's template executed
+// with the server's TemplateData if it is set.  An error in the template is
+// recorded as a reload error for the body's file, in which case the empty string
+// is returned and changed reports that the reload errors have changed.
+func (r *reloader) renderBody() (body string, changed bool) {
+	s := r.server
+	if s.TemplateData == nil || r.body == "" {
+		return r.body, false
+	}
+
+	out, err := s.executeTemplate(r.bodyPath, []byte(r.body))
+	if err != nil {
+		s.errorln("Failed to render", r.bodyPath, "--", err)
+		r.errors[r.bodyPath] = reloadError{
+			File:    r.bodyPath,
+			Line:    templateErrorLine(err),
+			Message: err.Error(),
+		}
+		return "", true
+	}
+	if _, ok := r.errors[r.bodyPath]; ok {
+		delete(r.errors, r.bodyPath)
+		changed = true
+	}
+	return string(out), changed
+}
+
+// Returns the current reload errors ordered by file.  The list is never nil so
+// that an empty list tells clients that every error has been resolved.
+func (r *reloader) errorList() []reloadError {
+	errs := make([]reloadError, 0, len(r.errors))
+	for _, e := range r.errors {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].File < errs[j].File })
+	return errs
+}
+
+// Returns the first of the server's ReloadRules, followed by the default rules,
+// that matches the slash separated path relative to ReloadWatchDir.
+func (s *Server) reloadRule(rel string) (ReloadRule, bool) {
+	for _, rules := range [][]ReloadRule{s.ReloadRules, defaultReloadRules} {
+		for _, rule := range rules {
+			name := rel
+			if !strings.Contains(rule.Pattern, "/") {
+				name = path.Base(rel)
+			}
+			match, err := path.Match(rule.Pattern, name)
+			if err != nil {
+				s.errorln("Failed to match reload rule pattern", rule.Pattern, "for path", rel, "--", err)
+			} else if match {
+				return rule, rule.Action != ReloadIgnore
+			}
+		}
+	}
+	return ReloadRule{}, false
+}
+
+// Returns the paths of all known CSS and Javascript files in the order their tags
+// appear in the document.  Files with a number between the last two dots of their
+// name come first in order of that number, followed by the rest of the files, and
+// ties are broken by path.
+func (r *reloader) order() []string {
+	type ordered struct {
+		path     string
+		n        int64
+		numbered bool
+	}
+
+	files := make([]ordered, 0, len(r.files))
+	for path := range r.files {
+		o := ordered{path: path}
+
+		sp := strings.Split(filepath.Base(path), ".")
+		ln := len(sp)
+		if ln >= 3 {
+			n := sp[ln-2]
+			if len(n) > 1 {
+				n = strings.TrimLeft(n, "0")
+			}
+			if i, err := strconv.ParseInt(n, 10, 32); err == nil {
+				o.n, o.numbered = i, true
+			}
+		}
+		files = append(files, o)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.numbered != b.numbered {
+			return a.numbered
+		}
+		if a.n != b.n {
+			return a.n < b.n
+		}
+		return a.path < b.path
+	})
+
+	order := make([]string, len(files))
+	for i, f := range files {
+		order[i] = f.path
+	}
+	return order
+}
@@ -0,0 +1,178 @@
+package gooey
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// Route is a page served by a Server at a path along with the App that handles the
+// connections made from that page.
+type Route struct {
+	// The HTML page served at the route's path, which like the index page must load
+	// gooey.js.  If the server's TemplateData is set then the page is executed as an
+	// html/template with its data.  If Page is the empty string then the server's
+	// index page is served, which allows a single page to route on the client.
+	Page string
+
+	// The App whose Start, or StartSession, is called for connections from the
+	// route's page.  If App is nil then the App given to Server.Start is used.
+	App App
+}
+
+// A running App of a connection along with the channels it was started with.
+type appRun struct {
+	pattern  string // The pattern of the route the App was started for.
+	route    Route
+	closed   chan struct{}
+	incoming chan []byte
+	outgoing chan interface{}
+}
+
+// Starts the App of the route matching the session's path, or app if there is no
+// such route or the route has no App.
+func (s *Server) runApp(app App, session *Session) *appRun {
+	pattern, route := s.matchRoute(session.Path)
+	if route.App != nil {
+		app = route.App
+	}
+
+	run := &appRun{
+		pattern:  pattern,
+		route:    route,
+		closed:   make(chan struct{}),
+		incoming: make(chan []byte),
+		outgoing: make(chan interface{}),
+	}
+	if sa, ok := app.(SessionApp); ok {
+		go sa.StartSession(session, run.closed, run.incoming, run.outgoing)
+	} else {
+		go app.Start(run.closed, run.incoming, run.outgoing)
+	}
+	return run
+}
+
+// Returns the pattern and route of the server's Routes that matches path exactly,
+// or the empty pattern and a zero Route if none do.
+func (s *Server) matchRoute(path string) (string, Route) {
+	if route, ok := s.Routes[path]; ok {
+		return path, route
+	}
+	return "", Route{}
+}
+
+// Reports whether the page at path is the index page, either because it is served
+// at the root or because it matches a route without a page of its own.
+func (s *Server) showsIndex(path string) bool {
+	if pattern, route := s.matchRoute(path); pattern != "" {
+		return route.Page == ""
+	}
+	return path == "" || path == "/" || path == "/index.html"
+}
+
+// Wraps the handler to serve the pages of the server's Routes.  A route without a
+// page is served the index page by passing the request on to the handler as a
+// request for "/".
+func (s *Server) serveRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, route := s.matchRoute(r.URL.Path)
+		if pattern == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if route.Page == "" {
+			index := *r.URL
+			index.Path = "/"
+			r2 := r.WithContext(r.Context())
+			r2.URL = &index
+			next.ServeHTTP(w, r2)
+			return
+		}
+		s.writePage(w, r, pattern, []byte(route.Page))
+	})
+}
+
+// The message gooey.js sends when gooey.Navigate changes the page's URL.
+type navigateMessage struct {
+	GooeyMessage string
+	GooeyContent string
+}
+
+// The reply to a navigation.  If the new route serves a different page than the
+// old one then Body is the content of the new page's body.
+type navigation struct {
+	Page string
+	Body string
+}
+
+var navigateName = []byte("gooey-client-navigate")
+
+// Returns the page, i.e. the path and query, navigated to if msg is a navigation
+// message from gooey.js.  Messages that don't mention the message's name are
+// passed over without being decoded.
+func navigateTo(msg []byte) (string, bool) {
+	if !bytes.Contains(msg, navigateName) {
+		return "", false
+	}
+	var nav navigateMessage
+	if err := json.Unmarshal(msg, &nav); err != nil || nav.GooeyMessage != string(navigateName) {
+		return "", false
+	}
+	return nav.GooeyContent, true
+}
+
+var bodyContent = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+
+// Returns the content of the body of the route's page, or of the index page if the
+// route has no page.
+func (s *Server) routeBody(route Route) string {
+	name, page := "index.html", []byte(route.Page)
+	if route.Page == "" {
+		var err error
+		if page, err = ioutil.ReadFile(s.index); err != nil {
+			s.errorln("Failed to read", s.index, "--", err)
+			return ""
+		}
+	}
+	if s.TemplateData != nil {
+		var err error
+		if page, err = s.executeTemplate(name, page); err != nil {
+			s.errorln("Failed to render page --", err)
+			return ""
+		}
+	}
+	m := bodyContent.FindSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// Sets the page, and so the path and params, of the client's session.  A new
+// Session is made so that the App started for the old page keeps its own.
+func (s *Server) navigateSession(c *client, page string) *Session {
+	session := *c.session
+	session.Path, session.Params = pageParams(page)
+
+	s.mu.Lock()
+	c.session = &session
+	s.mu.Unlock()
+
+	return &session
+}
+
+// Returns the path and the params of the page, without the parameters used by
+// gooey itself.
+func pageParams(page string) (string, url.Values) {
+	u, err := url.Parse(page)
+	if err != nil {
+		return "", url.Values{}
+	}
+	params := u.Query()
+	params.Del(windowParam)
+	params.Del("token")
+	return u.Path, params
+}
@@ -0,0 +1,26 @@
+package gooey
+
+import "testing"
+
+func TestNavigateTo(t *testing.T) {
+	tests := []struct {
+		msg  string
+		page string
+		ok   bool
+	}{
+		{`{"GooeyMessage":"gooey-client-navigate","GooeyContent":"/logs"}`, "/logs", true},
+		{`{"GooeyContent":"/logs?a=1","GooeyMessage":"gooey-client-navigate"}`, "/logs?a=1", true},
+		{` { "GooeyMessage" : "gooey-client-navigate", "GooeyContent" : "/" }`, "/", true},
+		{`{"GooeyMessage":"gooey-client-other","GooeyContent":"gooey-client-navigate"}`, "", false},
+		{`{"Type":"gooey-client-navigate"}`, "", false},
+		{`{"Type":"view"}`, "", false},
+		{`gooey-client-navigate`, "", false},
+	}
+
+	for _, test := range tests {
+		page, ok := navigateTo([]byte(test.msg))
+		if page != test.page || ok != test.ok {
+			t.Errorf("navigateTo(%s) = %q, %v, want %q, %v", test.msg, page, ok, test.page, test.ok)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package gooey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// SecurityHeaders configures the security related headers a Server adds to every
+// response.  A Server given a non nil SecurityHeaders will always send the
+// "X-Content-Type-Options: nosniff" header along with the headers configured below.
+type SecurityHeaders struct {
+	// The Content-Security-Policy header sent with every response.  Any occurrence
+	// of "{nonce}" is replaced with a random nonce that is generated for each
+	// request, so a policy can allow the inline scripts and styles of the index page
+	// with a source of 'nonce-{nonce}'.  If this field is the empty string then a
+	// strict default policy is used that only allows scripts, styles, and other
+	// content from the server itself along with inline scripts and styles carrying
+	// the request's nonce.
+	ContentSecurityPolicy string
+
+	// The sources allowed to embed the served pages in a frame which are appended to
+	// the Content-Security-Policy as the frame-ancestors directive.  If this field is
+	// the empty string then "'none'" is used, forbidding any framing of the pages.
+	FrameAncestors string
+
+	// The value of the Referrer-Policy header.  If this field is the empty string
+	// then "no-referrer" is used.
+	ReferrerPolicy string
+}
+
+const defaultCSP = "default-src 'self'; " +
+	"script-src 'self' 'nonce-{nonce}'; " +
+	"style-src 'self' 'nonce-{nonce}'; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self' ws://{host} wss://{host}; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'"
+
+var nonceTag = regexp.MustCompile(`<(script|style)(\s|>)`)
+
+type nonceKey struct{}
+
+// Wraps the handler to add the server's security headers to every response.  The
+// nonce generated for the request is stored in the request's context so that the
+// index page can be served with it.
+func (s *Server) secure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := randomNonce()
+		if err != nil {
+			s.errorln("Failed to generate CSP nonce --", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		h := s.SecurityHeaders
+		policy := h.ContentSecurityPolicy
+		if policy == "" {
+			policy = strings.Replace(defaultCSP, "{host}", r.Host, -1)
+		}
+		ancestors := h.FrameAncestors
+		if ancestors == "" {
+			ancestors = "'none'"
+		}
+		referrer := h.ReferrerPolicy
+		if referrer == "" {
+			referrer = "no-referrer"
+		}
+
+		header := w.Header()
+		header.Set("Content-Security-Policy", strings.Replace(policy, "{nonce}", nonce, -1)+"; frame-ancestors "+ancestors)
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", referrer)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce)))
+	})
+}
+
+// Returns a random base64 encoded value suitable for a CSP nonce.
+func randomNonce() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
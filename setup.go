@@ -3,11 +3,15 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"regexp"
+	"strings"
 )
 
 func main() {
@@ -21,6 +25,9 @@ func main() {
 				favgen(os.Args[2], packageName)
 			}
 
+		case "client":
+			clientgen()
+
 		default:
 			fmt.Println("Unrecognized setup command:", os.Args[1])
 		}
@@ -54,3 +61,90 @@ func favgen(faviconPath, packageName string) {
 	str := base64.StdEncoding.EncodeToString(fav)
 	fmt.Fprintf(out, FAVICON_GO, packageName, str)
 }
+
+const CLIENT_GO = `package gooey
+
+// This file is generated by "go run setup.go client" from gooey.js and gooey.d.ts.
+// Do not modify.
+
+// The version of the gooey client, which is a hash of gooey.js and gooey.d.ts.
+const CLIENT_VERSION = "%s"
+
+// The gooey client, gooey.js, as a classic script.
+const CLIENT_JS = %s
+
+// The gooey client as an ES module that exports the gooey object as its default
+// export along with its version.
+const CLIENT_MJS = %s
+
+// TypeScript declarations of CLIENT_MJS.
+const CLIENT_DTS = %s
+`
+
+var inlineClient = regexp.MustCompile(`(?s)<script>\n\(function ?\(\) ?\{.*?\}\)\(\);\n</script>`)
+
+// Generates client.go from gooey.js and gooey.d.ts, and replaces the client inlined
+// in the INDEX page of index.go with gooey.js stripped of its comments, so that
+// gooey.js is the one source of every form of the client.
+func clientgen() {
+	src, err := ioutil.ReadFile("gooey.js")
+	if err != nil {
+		log.Fatalln("Failed to read gooey.js --", err)
+	}
+	dts, err := ioutil.ReadFile("gooey.d.ts")
+	if err != nil {
+		log.Fatalln("Failed to read gooey.d.ts --", err)
+	}
+	index, err := ioutil.ReadFile("index.go")
+	if err != nil {
+		log.Fatalln("Failed to read index.go --", err)
+	}
+
+	js := strings.TrimRight(string(src), "\n") + "\n"
+	sum := sha256.Sum256([]byte(js + string(dts)))
+	version := hex.EncodeToString(sum[:])[:12]
+
+	// The module is the body of the IIFE that makes up gooey.js.
+	const head, tail = "(function () {\n", "})();\n"
+	if !strings.HasPrefix(js, head) || !strings.HasSuffix(js, tail) {
+		log.Fatalln("gooey.js must be a single (function () { ... })(); expression")
+	}
+	var mjs strings.Builder
+	mjs.WriteString("// The gooey client as an ES module, generated from gooey.js.\n")
+	for _, line := range strings.Split(strings.TrimSuffix(js[len(head):], tail), "\n") {
+		mjs.WriteString(strings.TrimPrefix(line, "    ") + "\n")
+	}
+	mjs.WriteString("export default gooey;\n")
+	mjs.WriteString("export const version = '" + version + "';\n")
+
+	// The inlined client drops comments and blank lines to keep the page small.
+	var lines []string
+	for _, line := range strings.Split(js, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "//") {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		}
+	}
+	inline := strings.Join(lines, "\n")
+	loc := inlineClient.FindIndex(index)
+	if loc == nil {
+		log.Fatalln("Failed to find the inlined client in index.go")
+	}
+	index = []byte(string(index[:loc[0]]) + "<script>\n" + inline + "\n</script>" + string(index[loc[1]:]))
+
+	out := fmt.Sprintf(CLIENT_GO, version, rawString(js), rawString(mjs.String()), rawString(string(dts)))
+	if err := ioutil.WriteFile("client.go", []byte(out), 0644); err != nil {
+		log.Fatalln("Failed to write client.go --", err)
+	}
+	if err := ioutil.WriteFile("index.go", index, 0644); err != nil {
+		log.Fatalln("Failed to write index.go --", err)
+	}
+}
+
+// Returns s as a Go raw string literal.
+func rawString(s string) string {
+	if strings.Contains(s, "`") {
+		log.Fatalln("The client can't contain a backquote")
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,100 @@
+package gooey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// Returns the TLS configuration the server should use for its listener or nil
+// if the server should serve plain HTTP.  The configuration is chosen in order
+// of TLSConfig, CertFile/KeyFile, and then SelfSignedTLS.
+func (s *Server) tlsConfig(addr net.Addr) (*tls.Config, error) {
+	if s.TLSConfig != nil {
+		return s.TLSConfig.Clone(), nil
+	}
+
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load TLS certificate and key -- %s", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+
+	if s.SelfSignedTLS {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		cert, err := selfSignedCert(host, addr)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to generate self-signed TLS certificate -- %s", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+
+	return nil, nil
+}
+
+// Generates a self-signed certificate that is valid for localhost, for host if it
+// is a name rather than an IP address, and for the IP address of addr.  If addr is
+// an unspecified address (e.g. "0.0.0.0") then the machine's host name and the
+// addresses of all the machine's network interfaces are used so that the
+// certificate is valid for other machines on the LAN.
+func selfSignedCert(host string, addr net.Addr) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Gooey Self-Signed"}},
+		NotBefore:             now.Add(-1 * time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	if host != "" && host != "localhost" && net.ParseIP(host) == nil {
+		tpl.DNSNames = append(tpl.DNSNames, host)
+	}
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		if tcp.IP == nil || tcp.IP.IsUnspecified() {
+			if name, err := os.Hostname(); err == nil && name != "" && name != host {
+				tpl.DNSNames = append(tpl.DNSNames, name)
+			}
+			if addrs, err := net.InterfaceAddrs(); err == nil {
+				for _, a := range addrs {
+					if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+						tpl.IPAddresses = append(tpl.IPAddresses, ipnet.IP)
+					}
+				}
+			}
+		} else if !tcp.IP.IsLoopback() {
+			tpl.IPAddresses = append(tpl.IPAddresses, tcp.IP)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tpl, &tpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
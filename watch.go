@@ -0,0 +1,192 @@
+package gooey
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/0xABAD/filewatch"
+)
+
+// Watches the root directory recursively for changes and sends each batch of
+// changes on the returned channel until done is closed.  The first batch holds
+// every file and directory within root as added.  Changes are detected from
+// file system events where the platform supports them, otherwise by polling
+// root on the server's ReloadInterval.  Paths for which ignore returns true are
+// never reported and ignored directories are not descended into at all.
+func (s *Server) watch(done <-chan struct{}, root string, ignore func(path string, isDir bool) bool) (<-chan []filewatch.Update, error) {
+	interval := s.ReloadInterval
+	if interval <= 0 {
+		interval = 1 * time.Second
+	}
+	debounce := s.ReloadDebounce
+	if debounce <= 0 {
+		debounce = 100 * time.Millisecond
+	}
+
+	if !s.ReloadPolling {
+		updates, err := watchEvents(done, root, debounce, ignore)
+		if err == nil {
+			s.infoln("Watching", root, "for file system events")
+			return updates, nil
+		}
+		s.infoln("Falling back to polling", root, "for changes --", err)
+	}
+
+	return pollChanges(done, root, interval, ignore)
+}
+
+// Scans root for changes on every interval.  Unlike filewatch.Watch, the scan
+// skips ignored directories so their contents cost nothing to watch.
+func pollChanges(done <-chan struct{}, root string, interval time.Duration, ignore func(path string, isDir bool) bool) (<-chan []filewatch.Update, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := func() (map[string]os.FileInfo, error) {
+		infos := make(map[string]os.FileInfo)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// Files may be removed while the scan walks the directory.
+				if path != root {
+					return nil
+				}
+				return err
+			}
+			if path != root && ignore(path, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			infos[path] = info
+			return nil
+		})
+		return infos, err
+	}
+
+	infos, err := scan()
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan []filewatch.Update)
+	send := func(us []filewatch.Update) bool {
+		sort.Slice(us, func(i, j int) bool { return us[i].AbsPath < us[j].AbsPath })
+		select {
+		case updates <- us:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	go func() {
+		defer close(updates)
+
+		initial := make([]filewatch.Update, 0, len(infos))
+		for path, info := range infos {
+			initial = append(initial, filewatch.Update{AbsPath: path, Next: info, WasAdded: true})
+		}
+		if !send(initial) {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				next, err := scan()
+				if err != nil {
+					continue
+				}
+
+				var changed []filewatch.Update
+				for path, info := range next {
+					prev, ok := infos[path]
+					if !ok {
+						changed = append(changed, filewatch.Update{AbsPath: path, Next: info, WasAdded: true})
+					} else if prev.ModTime().Before(info.ModTime()) || prev.Size() != info.Size() {
+						changed = append(changed, filewatch.Update{AbsPath: path, Prev: prev, Next: info})
+					}
+				}
+				for path, prev := range infos {
+					if _, ok := next[path]; !ok {
+						changed = append(changed, filewatch.Update{AbsPath: path, Prev: prev, WasRemoved: true})
+					}
+				}
+				infos = next
+
+				if len(changed) > 0 && !send(changed) {
+					return
+				}
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// Collects updates that arrive in bursts, such as the several writes and renames
+// an editor does when saving a file, and sends them as one batch once no update
+// has arrived for the debounce duration.  Later updates to a path replace earlier
+// ones.  The out channel is closed once done is closed or in is closed.
+func debounceUpdates(done <-chan struct{}, in <-chan []filewatch.Update, out chan<- []filewatch.Update, debounce time.Duration) {
+	defer close(out)
+
+	var (
+		pending = make(map[string]filewatch.Update)
+		timer   = time.NewTimer(debounce)
+		fire    <-chan time.Time
+	)
+	timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			timer.Stop()
+			return
+
+		case us, ok := <-in:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			for _, u := range us {
+				if prev, ok := pending[u.AbsPath]; ok && !u.WasRemoved {
+					u.WasAdded = u.WasAdded || prev.WasAdded
+				}
+				pending[u.AbsPath] = u
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(debounce)
+			fire = timer.C
+
+		case <-fire:
+			fire = nil
+			batch := make([]filewatch.Update, 0, len(pending))
+			for _, u := range pending {
+				batch = append(batch, u)
+			}
+			sort.Slice(batch, func(i, j int) bool { return batch[i].AbsPath < batch[j].AbsPath })
+			pending = make(map[string]filewatch.Update)
+
+			select {
+			case out <- batch:
+			case <-done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package gooey
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/0xABAD/filewatch"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// Watches a directory tree with inotify.  Every directory within the tree has
+// its own inotify watch, which is mapped back to the directory's path.  Since
+// inotify reports only the directory itself when a directory is removed or moved
+// away, every path that has been reported is kept so that the removal of all
+// paths under the directory can be reported too.
+type inotifyWatcher struct {
+	fd     int
+	file   *os.File
+	root   string
+	dirs   map[int32]string
+	paths  map[string]struct{}
+	ignore func(path string, isDir bool) bool
+}
+
+// Watches root recursively with inotify.  The updates are debounced so that a
+// burst of events is sent as one batch.  Ignored directories are not watched.
+func watchEvents(done <-chan struct{}, root string, debounce time.Duration, ignore func(path string, isDir bool) bool) (<-chan []filewatch.Update, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// Since the descriptor is non-blocking the returned file uses the runtime's
+	// poller, which allows closing the file to interrupt a pending Read.
+	w := &inotifyWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		root:   root,
+		dirs:   make(map[int32]string),
+		paths:  make(map[string]struct{}),
+		ignore: ignore,
+	}
+
+	var initial []filewatch.Update
+	if err := w.addTree(root, &initial); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	var (
+		events  = make(chan []filewatch.Update)
+		updates = make(chan []filewatch.Update)
+	)
+
+	go w.read(done, events)
+	go func() {
+		defer w.file.Close()
+
+		select {
+		case updates <- initial:
+		case <-done:
+			close(updates)
+			return
+		}
+		debounceUpdates(done, events, updates, debounce)
+	}()
+
+	return updates, nil
+}
+
+// Adds a watch for the directory at root and every directory beneath it.  Each
+// file and directory found is appended to added as an added update.
+func (w *inotifyWatcher) addTree(root string, added *[]filewatch.Update) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The directory may have been removed before we walked it.
+			if os.IsNotExist(err) && path != root {
+				return nil
+			}
+			return err
+		}
+		if path != root && w.ignore(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+			if err != nil {
+				return os.NewSyscallError("inotify_add_watch", err)
+			}
+			w.dirs[int32(wd)] = path
+		}
+		w.paths[path] = struct{}{}
+		*added = append(*added, filewatch.Update{AbsPath: path, Next: info, WasAdded: true})
+		return nil
+	})
+}
+
+// Appends a removed update for path and, if path is a directory, for every path
+// beneath it.  The watches of the removed directories are removed as well, as a
+// directory that was moved keeps its watch and would otherwise report its events
+// under its old path.
+func (w *inotifyWatcher) removeTree(path string, removed *[]filewatch.Update) {
+	prefix := path + string(filepath.Separator)
+
+	*removed = append(*removed, filewatch.Update{AbsPath: path, WasRemoved: true})
+	delete(w.paths, path)
+	for p := range w.paths {
+		if strings.HasPrefix(p, prefix) {
+			*removed = append(*removed, filewatch.Update{AbsPath: p, WasRemoved: true})
+			delete(w.paths, p)
+		}
+	}
+
+	for wd, dir := range w.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			// A deleted directory has already lost its watch so errors are expected.
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// Walks the whole tree again after inotify's event queue overflowed, and so events
+// were lost.  Every path found is appended to updates, which makes clients reload
+// it, along with a removed update for every path that no longer exists.
+func (w *inotifyWatcher) rescan(updates *[]filewatch.Update) {
+	known := w.paths
+	w.paths = make(map[string]struct{})
+
+	if err := w.addTree(w.root, updates); err != nil {
+		*updates = append(*updates, filewatch.Update{AbsPath: w.root, Error: err})
+	}
+	for p := range known {
+		if _, ok := w.paths[p]; !ok {
+			*updates = append(*updates, filewatch.Update{AbsPath: p, WasRemoved: true})
+		}
+	}
+	for wd, dir := range w.dirs {
+		if _, ok := w.paths[dir]; !ok {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// Reads inotify events until the inotify file is closed, or done is closed, and
+// sends the updates they describe to the events channel.
+func (w *inotifyWatcher) read(done <-chan struct{}, events chan<- []filewatch.Update) {
+	defer close(events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		var updates []filewatch.Update
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			start := off + syscall.SizeofInotifyEvent
+			end := start + int(event.Len)
+			off = end
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				w.rescan(&updates)
+				continue
+			}
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, event.Wd)
+				continue
+			}
+			dir, ok := w.dirs[event.Wd]
+			if !ok || event.Len == 0 {
+				continue
+			}
+
+			name := string(buf[start:end])
+			for i := 0; i < len(name); i++ {
+				if name[i] == 0 {
+					name = name[:i]
+					break
+				}
+			}
+			path := filepath.Join(dir, name)
+			if w.ignore(path, event.Mask&syscall.IN_ISDIR != 0) {
+				continue
+			}
+
+			switch {
+			case event.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+				w.removeTree(path, &updates)
+
+			case event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+				info, err := os.Lstat(path)
+				if err != nil {
+					continue
+				}
+				if info.IsDir() {
+					if err := w.addTree(path, &updates); err != nil {
+						updates = append(updates, filewatch.Update{AbsPath: path, Next: info, Error: err, WasAdded: true})
+					}
+				} else {
+					w.paths[path] = struct{}{}
+					updates = append(updates, filewatch.Update{AbsPath: path, Next: info, WasAdded: true})
+				}
+
+			default:
+				info, err := os.Lstat(path)
+				if os.IsNotExist(err) {
+					delete(w.paths, path)
+				}
+				updates = append(updates, filewatch.Update{AbsPath: path, Next: info, Error: err, WasRemoved: os.IsNotExist(err)})
+			}
+		}
+
+		if len(updates) > 0 {
+			select {
+			case events <- updates:
+			case <-done:
+				return
+			}
+		}
+	}
+}
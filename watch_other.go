@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package gooey
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/0xABAD/filewatch"
+)
+
+func watchEvents(done <-chan struct{}, root string, debounce time.Duration, ignore func(path string, isDir bool) bool) (<-chan []filewatch.Update, error) {
+	return nil, fmt.Errorf("file system events are not supported on %s", runtime.GOOS)
+}
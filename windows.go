@@ -0,0 +1,133 @@
+package gooey
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNoWindow is returned by Server.SendTo when no client of the named window is
+// connected.
+var ErrNoWindow = errors.New("gooey: no client is connected for the window")
+
+// The query parameter of a page's URL that names the window the page is shown in.
+// gooey.js stores it as the browser window's name so that it's kept when the page
+// is refreshed or navigates away and back.
+const windowParam = "gooeywindow"
+
+// OpenWindow opens a new browser window, with the server's Launcher, showing the
+// page at path with params as the page's query.  The page's connection will have
+// a Session whose Window is name and whose Params are params, which allows the App
+// to tell windows apart and to address messages to the window with SendTo.  The
+// path must be absolute, such as "/" or "/detail.html".
+//
+// Note that the server can't find an already open window by its name, so every
+// call opens another window.  gooey.OpenWindow in gooey.js instead reuses the
+// window of the same name if one is open.
+func (s *Server) OpenWindow(name, path string, params url.Values) error {
+	u, err := s.windowURL(name, path, params)
+	if err != nil {
+		return err
+	}
+	if !s.launchable {
+		return fmt.Errorf("Can't open a window for a server that isn't listening on TCP")
+	}
+	return s.open(u)
+}
+
+// Returns the URL of the page at path, with params, shown in the named window.
+func (s *Server) windowURL(name, path string, params url.Values) (string, error) {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return "", fmt.Errorf("Window path must be absolute, not %q", path)
+	}
+	if s.url == "" {
+		return "", fmt.Errorf("Server has not started")
+	}
+
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query() // Keeps the token of a TokenAuth.
+	for k, vs := range params {
+		for _, v := range vs {
+			query.Add(k, v)
+		}
+	}
+	if name != "" {
+		query.Set(windowParam, name)
+	}
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// SendTo sends msg to every client connected from the named window, as the App
+// would through the outgoing channel of those connections.  ErrNoWindow is returned
+// if no client of the window is connected.
+func (s *Server) SendTo(window string, msg interface{}) error {
+	s.mu.Lock()
+	var targets []*client
+	for c := range s.clients {
+		if c.session.Window == window {
+			targets = append(targets, c)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(targets) == 0 {
+		return ErrNoWindow
+	}
+	for _, c := range targets {
+		select {
+		case c.direct <- msg:
+		case <-c.gone:
+		}
+	}
+	return nil
+}
+
+func (s *Server) addClient(c *client) {
+	s.mu.Lock()
+	if s.clients == nil {
+		s.clients = make(map[*client]struct{})
+	}
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *Server) removeClient(c *client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+}
+
+// Fills in the window and page of the session from the websocket request made by
+// gooey.js, which gives the name of its window and the URL of its page.
+func pageSession(session *Session, r *http.Request) {
+	q := r.URL.Query()
+	session.Window = q.Get("window")
+	session.Path, session.Params = pageParams(q.Get("page"))
+}
+
+// Opens a new tab, or a named window when requested by gooey.OpenWindow.
+func (s *Server) handleNewTab(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	path := q.Get("path")
+	if path == "" {
+		s.launch(s.url)
+		return
+	}
+
+	params, err := url.ParseQuery(q.Get("query"))
+	if err == nil {
+		err = s.OpenWindow(q.Get("window"), path, params)
+	}
+	if err != nil {
+		s.errorln("Failed to open window --", err)
+		http.Error(w, "Failed to open window", http.StatusBadRequest)
+	}
+}